@@ -2,19 +2,28 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/rest"
 
 	"github.com/johandry/nfs-operator/pkg/apis"
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	ibmcloudv1alpha2 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha2"
 	"github.com/johandry/nfs-operator/pkg/controller"
+	nfscontroller "github.com/johandry/nfs-operator/pkg/controller/nfs"
+	nfsprovisioner "github.com/johandry/nfs-operator/pkg/resources/provisioner/nfs"
 	"github.com/johandry/nfs-operator/version"
 
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
@@ -28,6 +37,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
@@ -39,6 +49,22 @@ var (
 	metricsPort         int32 = 8383
 	operatorMetricsPort int32 = 8686
 )
+
+// Change below variables to serve the admission webhooks on a different port or
+// with the certificates from a different directory.
+var (
+	enableWebhooks bool
+	webhookPort    = 9443
+	webhookCertDir = "/tmp/k8s-webhook-server/serving-certs"
+)
+
+// Change below variable to serve the health probes, /healthz and /readyz, on a
+// different address.
+var healthProbeAddr = ":8081"
+
+// healthCheckTimeout is the time a readiness check waits for its component
+const healthCheckTimeout = time.Second
+
 var log = logf.Log.WithName("cmd")
 
 func printVersion() {
@@ -57,6 +83,36 @@ func main() {
 	// controller-runtime)
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 
+	// The webhook server requires a TLS certificate, it's disabled by default to
+	// allow running the operator locally or in a cluster without certificates
+	pflag.BoolVar(&enableWebhooks, "enable-webhooks", false, "Serve the admission webhooks for the Nfs resources")
+	pflag.IntVar(&webhookPort, "webhook-port", webhookPort, "Port to serve the admission webhooks")
+	pflag.StringVar(&webhookCertDir, "webhook-cert-dir", webhookCertDir, "Directory with the tls.crt and tls.key files to serve the admission webhooks")
+
+	pflag.StringVar(&healthProbeAddr, "health-probe-bind-address", healthProbeAddr, "Address to serve the /healthz and /readyz probes, 0 to disable them")
+
+	// The manifests templates of the NFS provisioner resources can be overridden
+	// by the cluster admin with a ConfigMap
+	pflag.StringVar(&nfscontroller.ManifestsConfigMap, "manifests-configmap", os.Getenv("MANIFESTS_CONFIGMAP"), "ConfigMap, as <namespace>/<name>, with the manifests templates that override the embedded ones")
+
+	// The NFS provisioner image used by the Nfs that do not set one
+	if image := os.Getenv("PROVISIONER_IMAGE"); len(image) != 0 {
+		nfsprovisioner.DefaultImage = image
+	}
+	pflag.StringVar(&nfsprovisioner.DefaultImage, "provisioner-image", nfsprovisioner.DefaultImage, "Default NFS provisioner image, as a tag or a digest, for the Nfs without spec.provisioner.image")
+
+	// The extra resources are applied with the operator permissions, the cluster
+	// admin allows the kinds the Nfs authors can create
+	if kinds := os.Getenv("EXTRA_RESOURCES_KINDS"); len(kinds) != 0 {
+		ibmcloudv1alpha1.AllowedExtraKinds = strings.Split(kinds, ",")
+	}
+	pflag.StringSliceVar(&ibmcloudv1alpha1.AllowedExtraKinds, "extra-resources-kinds", ibmcloudv1alpha1.AllowedExtraKinds, "Kinds, as Kind.group or Kind for the core group, allowed in the extra resources of the Nfs, none by default")
+
+	// The filesystem usage is collected from the kubelet stats, it requires the
+	// nodes/proxy permission granted with deploy/cluster_role_usage.yaml
+	ibmcloudv1alpha1.UsageCollection, _ = strconv.ParseBool(os.Getenv("USAGE_COLLECTION"))
+	pflag.BoolVar(&ibmcloudv1alpha1.UsageCollection, "usage-collection", ibmcloudv1alpha1.UsageCollection, "Collect the filesystem usage of the Nfs from the kubelet stats, it requires the nodes/proxy permission")
+
 	pflag.Parse()
 
 	// Use a zap logr.Logger implementation. If none of the zap
@@ -96,6 +152,12 @@ func main() {
 	options := manager.Options{
 		Namespace:          namespace,
 		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
+		Port:               webhookPort,
+		CertDir:            webhookCertDir,
+
+		HealthProbeBindAddress: healthProbeAddr,
+		LivenessEndpointName:   "/healthz",
+		ReadinessEndpointName:  "/readyz",
 	}
 
 	// Add support for MultiNamespace set in WATCH_NAMESPACE (e.g ns1,ns2)
@@ -128,6 +190,24 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Setup the admission webhooks
+	if enableWebhooks {
+		if err := (&ibmcloudv1alpha1.Nfs{}).SetupWebhookWithManager(mgr); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+		if err := (&ibmcloudv1alpha2.Nfs{}).SetupWebhookWithManager(mgr); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	}
+
+	// Setup the health probes
+	if err := addHealthChecks(mgr); err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+
 	// Add the Metrics Service
 	addMetrics(ctx, cfg)
 
@@ -140,6 +220,51 @@ func main() {
 	}
 }
 
+// addHealthChecks adds the liveness and readiness checks of the manager. The
+// operator is alive while the manager serves the probes, and ready once the
+// informers cache is synced and, if enabled, the webhook server is serving
+func addHealthChecks(mgr manager.Manager) error {
+	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		return err
+	}
+	if err := mgr.AddReadyzCheck("cache-sync", cacheSyncCheck(mgr.GetCache())); err != nil {
+		return err
+	}
+	if enableWebhooks {
+		if err := mgr.AddReadyzCheck("webhook", webhookCheck(webhookPort)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cacheSyncCheck returns a check that fails until the informers cache is synced
+func cacheSyncCheck(c cache.Cache) healthz.Checker {
+	return func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), healthCheckTimeout)
+		defer cancel()
+		if !c.WaitForCacheSync(ctx.Done()) {
+			return errors.New("the informers cache is not synced")
+		}
+		return nil
+	}
+}
+
+// webhookCheck returns a check that fails until the webhook server accepts TLS
+// connections on the given port. The certificate is not verified, it's issued
+// for the webhook Service and not for localhost
+func webhookCheck(port int) healthz.Checker {
+	addr := net.JoinHostPort("localhost", fmt.Sprint(port))
+	return func(req *http.Request) error {
+		dialer := &net.Dialer{Timeout: healthCheckTimeout}
+		conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			return fmt.Errorf("the webhook server is not serving. %s", err)
+		}
+		return conn.Close()
+	}
+}
+
 // addMetrics will create the Services and Service Monitors to allow the operator export the metrics by using
 // the Prometheus operator
 func addMetrics(ctx context.Context, cfg *rest.Config) {
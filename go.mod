@@ -5,11 +5,13 @@ go 1.13
 require (
 	github.com/go-logr/logr v0.1.0
 	github.com/operator-framework/operator-sdk v0.18.2
+	github.com/prometheus/client_golang v1.5.1
 	github.com/spf13/pflag v1.0.5
 	k8s.io/api v0.18.2
 	k8s.io/apimachinery v0.18.2
 	k8s.io/client-go v12.0.0+incompatible
 	sigs.k8s.io/controller-runtime v0.6.0
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace (
@@ -0,0 +1,5 @@
+package v1alpha1
+
+// Hub marks v1alpha1 as the version stored by the API server, the other
+// versions of the Nfs are converted to and from it
+func (*Nfs) Hub() {}
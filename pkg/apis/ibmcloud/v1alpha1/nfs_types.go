@@ -1,17 +1,338 @@
 package v1alpha1
 
 import (
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// BackendType is the type of storage used as backing storage
+// +kubebuilder:validation:Enum=vpc-block;pvc;hostPath;emptyDir
+type BackendType string
+
+const (
+	// BackendVPCBlock is a claim of IBM Cloud VPC Block storage
+	BackendVPCBlock BackendType = "vpc-block"
+	// BackendPVC is a claim of any storage class
+	BackendPVC BackendType = "pvc"
+	// BackendHostPath is a directory on the node running the NFS provisioner
+	BackendHostPath BackendType = "hostPath"
+	// BackendEmptyDir is an ephemeral volume, the data is lost when the NFS
+	// provisioner Pod is deleted
+	BackendEmptyDir BackendType = "emptyDir"
+)
+
 // BackingStorageSpec defines the desired state of the Backing Storage
 type BackingStorageSpec struct {
+	// Type is the type of backing storage: vpc-block, pvc, hostPath or emptyDir
+	// +optional
+	// +kubebuilder:default=vpc-block
+	Type BackendType `json:"type,omitempty"`
+
+	// PvcName is the name of the claim used as backing storage, if not set it's
+	// the Nfs name with the suffix "-nfs-block"
 	PvcName      string `json:"pvcName,omitempty"`
 	StorageClass string `json:"storageClass,omitempty"`
 	StorageSize  string `json:"storageSize,omitempty"`
+
+	// HostPath is the directory on the node used by the hostPath backing
+	// storage, if not set it's /var/lib/nfs-operator/<namespace>/<name>
+	// +optional
+	HostPath string `json:"hostPath,omitempty"`
+
+	// AutoExpand grows the claim created by the operator before the exported
+	// volume fills up
+	// +optional
+	AutoExpand *AutoExpandSpec `json:"autoExpand,omitempty"`
+}
+
+// AutoExpandSpec is the policy to grow the backing storage claim when the used
+// space of the exported volume reaches a percentage. It requires the
+// filesystem usage and a storage class that allows volume expansion
+type AutoExpandSpec struct {
+	// TriggerPercent is the percentage of used space that grows the claim
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	TriggerPercent int32 `json:"triggerPercent"`
+
+	// Step is the size added to the claim on every expansion
+	Step string `json:"step"`
+
+	// MaxSize is the size the claim does not grow beyond
+	MaxSize string `json:"maxSize"`
+
+	// Cooldown is the minimum time between expansions, 15m if not set. The claim
+	// is not expanded either while a resize is in progress
+	// +optional
+	Cooldown *metav1.Duration `json:"cooldown,omitempty"`
+}
+
+// DeletionPolicy describes what happens to the backing storage when the Nfs is
+// deleted
+// +kubebuilder:validation:Enum=Retain;Delete
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyRetain keeps the backing storage when the Nfs is deleted
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyDelete removes the backing storage when the Nfs is deleted
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+)
+
+// StorageClassTemplateSpec has the settings of the StorageClass served by the
+// NFS provisioner. The provisioner, parameters, reclaim policy and volume
+// binding mode of a StorageClass cannot be updated, when they change the
+// StorageClass is deleted and created again
+type StorageClassTemplateSpec struct {
+	// Labels are added to the labels of the StorageClass
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are added to the annotations of the StorageClass
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Parameters are the parameters of the StorageClass for the NFS provisioner
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+
+	// MountOptions are the mount options of the volumes, if not set it's the
+	// NFS version 4.1, or 3 if the exports serve only the NFS version 3
+	// +optional
+	MountOptions []string `json:"mountOptions,omitempty"`
+
+	// ReclaimPolicy is the reclaim policy of the volumes: Delete or Retain
+	// +optional
+	// +kubebuilder:validation:Enum=Delete;Retain
+	ReclaimPolicy *corev1.PersistentVolumeReclaimPolicy `json:"reclaimPolicy,omitempty"`
+
+	// AllowVolumeExpansion allows to expand the claims of the StorageClass
+	// +optional
+	AllowVolumeExpansion *bool `json:"allowVolumeExpansion,omitempty"`
+
+	// VolumeBindingMode is when the volumes are provisioned and bound:
+	// Immediate or WaitForFirstConsumer
+	// +optional
+	// +kubebuilder:validation:Enum=Immediate;WaitForFirstConsumer
+	VolumeBindingMode *storagev1.VolumeBindingMode `json:"volumeBindingMode,omitempty"`
+
+	// Default marks the StorageClass as the cluster default. It's refused if
+	// other StorageClass is the default
+	// +optional
+	Default bool `json:"default,omitempty"`
+}
+
+// StorageClassProfile is other StorageClass served by the same NFS provisioner,
+// with its own settings
+type StorageClassProfile struct {
+	// Name is the name of the StorageClass
+	Name string `json:"name"`
+
+	StorageClassTemplateSpec `json:",inline"`
+}
+
+// ExportAccess is the access granted to the clients of the exports
+// +kubebuilder:validation:Enum=ReadWrite;ReadOnly
+type ExportAccess string
+
+const (
+	// ExportReadWrite allows the clients to read and write
+	ExportReadWrite ExportAccess = "ReadWrite"
+	// ExportReadOnly allows the clients to read only
+	ExportReadOnly ExportAccess = "ReadOnly"
+)
+
+// ExportSquash is the user mapping applied to the clients of the exports
+// +kubebuilder:validation:Enum=None;Root;All
+type ExportSquash string
+
+const (
+	// ExportSquashNone keeps the user of the clients
+	ExportSquashNone ExportSquash = "None"
+	// ExportSquashRoot maps the root user of the clients to the anonymous user
+	ExportSquashRoot ExportSquash = "Root"
+	// ExportSquashAll maps every user of the clients to the anonymous user
+	ExportSquashAll ExportSquash = "All"
+)
+
+// NFSVersion is a major version of the NFS protocol
+// +kubebuilder:validation:Enum=3;4
+type NFSVersion int32
+
+// ExportsSpec defines how the NFS provisioner exports the volumes to the clients
+type ExportsSpec struct {
+	// Access is the access granted to the clients: ReadWrite or ReadOnly
+	// +optional
+	// +kubebuilder:default=ReadWrite
+	Access ExportAccess `json:"access,omitempty"`
+
+	// Squash is the user mapping applied to the clients: None, Root or All
+	// +optional
+	// +kubebuilder:default=None
+	Squash ExportSquash `json:"squash,omitempty"`
+
+	// AnonymousUID is the user ID of the squashed users
+	// +optional
+	AnonymousUID *int64 `json:"anonymousUID,omitempty"`
+
+	// AnonymousGID is the group ID of the squashed users
+	// +optional
+	AnonymousGID *int64 `json:"anonymousGID,omitempty"`
+
+	// AllowedClients are the IP addresses or CIDRs of the clients allowed to
+	// mount the volumes, if not set every client is allowed
+	// +optional
+	AllowedClients []string `json:"allowedClients,omitempty"`
+
+	// NFSVersions are the versions of the NFS protocol served, if not set the
+	// versions 3 and 4 are served
+	// +optional
+	NFSVersions []NFSVersion `json:"nfsVersions,omitempty"`
+}
+
+// ProvisionerSpec defines the desired state of the NFS provisioner
+type ProvisionerSpec struct {
+	// Image is the NFS provisioner container image, as a tag or a digest. If not
+	// set the operator default is used
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// ImagePullSecrets are the Secrets, in the Nfs namespace, used to pull the
+	// NFS provisioner image
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// PodTemplate has the scheduling and resource settings of the NFS
+	// provisioner Pod
+	// +optional
+	PodTemplate PodTemplateSpec `json:"podTemplate,omitempty"`
+
+	// Probes are the liveness and readiness probes of the NFS server
+	// +optional
+	Probes ProbesSpec `json:"probes,omitempty"`
+}
+
+// ProbeType is how the NFS server is probed
+// +kubebuilder:validation:Enum=RPC;TCP
+type ProbeType string
+
+const (
+	// ProbeRPC calls the NFS service through rpcbind, it fails if rpcbind or
+	// the NFS server do not answer
+	ProbeRPC ProbeType = "RPC"
+	// ProbeTCP opens a connection to the NFS port, it does not detect a hung
+	// NFS server that still accepts connections
+	ProbeTCP ProbeType = "TCP"
+)
+
+// ProbeSpec has the settings of a probe of the NFS server. The settings not set
+// are the operator defaults
+type ProbeSpec struct {
+	// Disabled removes the probe from the NFS provisioner container
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Type is how the NFS server is probed: RPC (default) or TCP
+	// +optional
+	Type ProbeType `json:"type,omitempty"`
+
+	// InitialDelaySeconds is the time after the container starts before the
+	// first probe
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	InitialDelaySeconds *int32 `json:"initialDelaySeconds,omitempty"`
+
+	// PeriodSeconds is the time between probes
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	PeriodSeconds *int32 `json:"periodSeconds,omitempty"`
+
+	// TimeoutSeconds is the time after which the probe fails
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+
+	// FailureThreshold is the number of consecutive failures to consider the
+	// NFS server not ready, or to restart it
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	FailureThreshold *int32 `json:"failureThreshold,omitempty"`
+}
+
+// ProbesSpec has the probes of the NFS server
+type ProbesSpec struct {
+	// Liveness restarts the NFS provisioner container when it fails
+	// +optional
+	Liveness ProbeSpec `json:"liveness,omitempty"`
+
+	// Readiness removes the NFS provisioner Pod from the Service when it fails
+	// +optional
+	Readiness ProbeSpec `json:"readiness,omitempty"`
+}
+
+// PodTemplateSpec has the settings of the NFS provisioner Pod. They are applied
+// over the Deployment manifest template, so they take precedence over it
+type PodTemplateSpec struct {
+	// Annotations are added to the annotations of the Pod
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// NodeSelector is added to the node selector of the Pod, to run it only on
+	// the nodes with these labels
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations are added to the tolerations of the Pod
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity replaces the scheduling constraints of the Pod
+	// +optional
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// PriorityClassName is the name of the PriorityClass of the Pod
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// Resources replaces the compute resources of the NFS provisioner container
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Env are added to the environment variables of the NFS provisioner
+	// container. The variables set by the operator cannot be replaced
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+}
+
+// UsageSpec has the settings of the report of the filesystem usage of the
+// exported volume, collected from the kubelet volume stats of the NFS
+// provisioner Pod
+type UsageSpec struct {
+	// Disabled stops collecting the filesystem usage
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Interval is the time between collections of the filesystem usage, 1m if
+	// not set. It cannot be less than 10s
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// WarningThreshold is the percentage of used space or inodes that sets the
+	// StorageUsageHigh condition to True. If not set the condition is not
+	// reported
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	WarningThreshold *int32 `json:"warningThreshold,omitempty"`
 }
 
 // NfsSpec defines the desired state of Nfs
@@ -20,16 +341,104 @@ type NfsSpec struct {
 	// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
 	// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
 
+	// StorageClass is the name of the StorageClass served by the NFS provisioner,
+	// if not set it's the Nfs namespace and name
 	// +optional
-	// +kubebuilder:default=example-nfs
 	StorageClass string `json:"storageClass,omitempty"`
 
+	// ProvisionerAPI is the name of the NFS provisioner, if not set it's unique
+	// for the Nfs namespace and name
 	// +optional
-	// +kubebuilder:default=example.com/nfs
 	ProvisionerAPI string `json:"provisionerAPI,omitempty"`
 
+	// StorageClassTemplate has the settings of the StorageClass
+	// +optional
+	StorageClassTemplate StorageClassTemplateSpec `json:"storageClassTemplate,omitempty"`
+
+	// StorageClassProfiles are other StorageClasses served by the NFS
+	// provisioner, the StorageClasses removed from the list are deleted
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	StorageClassProfiles []StorageClassProfile `json:"storageClassProfiles,omitempty"`
+
 	// +optional
 	BackingStorage BackingStorageSpec `json:"backingStorage,omitempty"`
+
+	// DeletionPolicy defines if the backing storage is kept (Retain) or removed
+	// (Delete) when the Nfs is deleted
+	// +optional
+	// +kubebuilder:default=Delete
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// Exports defines how the volumes are exported to the clients
+	// +optional
+	Exports ExportsSpec `json:"exports,omitempty"`
+
+	// Provisioner has the settings of the NFS provisioner Deployment
+	// +optional
+	Provisioner ProvisionerSpec `json:"provisioner,omitempty"`
+
+	// Usage has the settings of the report of the filesystem usage of the
+	// exported volume
+	// +optional
+	Usage UsageSpec `json:"usage,omitempty"`
+
+	// ExtraResources are manifests of other resources created with the NFS
+	// provisioner, like a NetworkPolicy or a ServiceMonitor. They are Go
+	// templates rendered with the names of the Nfs and its resources, and they
+	// are deleted with the Nfs
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	ExtraResources []runtime.RawExtension `json:"extraResources,omitempty"`
+}
+
+// UsageStatus is the filesystem usage of the exported volume
+type UsageStatus struct {
+	// Capacity is the size of the filesystem
+	Capacity resource.Quantity `json:"capacity"`
+	// Used is the space used in the filesystem
+	Used resource.Quantity `json:"used"`
+	// Available is the space available in the filesystem
+	Available resource.Quantity `json:"available"`
+	// UsedPercent is the percentage of the capacity used
+	UsedPercent int32 `json:"usedPercent"`
+
+	// Inodes is the number of inodes of the filesystem
+	Inodes int64 `json:"inodes"`
+	// InodesUsed is the number of inodes used in the filesystem
+	InodesUsed int64 `json:"inodesUsed"`
+	// InodesFree is the number of inodes free in the filesystem
+	InodesFree int64 `json:"inodesFree"`
+
+	// LastUpdateTime is when the filesystem usage was collected
+	LastUpdateTime metav1.Time `json:"lastUpdateTime"`
+}
+
+// AutoExpansionStatus is the record of the automatic expansions of the backing
+// storage claim
+type AutoExpansionStatus struct {
+	// Count is the number of automatic expansions
+	Count int32 `json:"count"`
+	// LastExpansionTime is when the claim was expanded the last time
+	LastExpansionTime metav1.Time `json:"lastExpansionTime"`
+	// From is the size of the claim before the last expansion
+	From resource.Quantity `json:"from"`
+	// To is the size of the claim requested by the last expansion
+	To resource.Quantity `json:"to"`
+}
+
+// ExtraResourceReference identifies an extra resource applied by the operator
+type ExtraResourceReference struct {
+	// APIVersion is the API version of the resource
+	APIVersion string `json:"apiVersion"`
+	// Kind is the kind of the resource
+	Kind string `json:"kind"`
+	// Namespace is the namespace of the resource, empty if it's cluster scoped
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Name is the name of the resource
+	Name string `json:"name"`
 }
 
 // NfsStatus defines the observed state of Nfs
@@ -40,16 +449,96 @@ type NfsStatus struct {
 
 	Capacity   string `json:"capacity,omitempty"`
 	AccessMode string `json:"accessMode,omitempty"`
-	Status     string `json:"status,omitempty"`
+
+	// RequestedCapacity is the size requested on the backing storage claim while
+	// it's resized, the Capacity is its current size
+	// +optional
+	RequestedCapacity string `json:"requestedCapacity,omitempty"`
+
+	// Status is the phase of the Nfs: Pending, Ready or Failed
+	// +optional
+	Status string `json:"status,omitempty"`
+
+	// ObservedGeneration is the most recent generation observed by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ProvisionerImage is the image of the NFS provisioner Pod running once the
+	// latest Deployment rollout is complete
+	// +optional
+	ProvisionerImage string `json:"provisionerImage,omitempty"`
+
+	// ProvisionerImageID is the ID, with the digest, of the image pulled by the
+	// NFS provisioner Pod
+	// +optional
+	ProvisionerImageID string `json:"provisionerImageID,omitempty"`
+
+	// Usage is the latest filesystem usage collected from the exported volume
+	// +optional
+	Usage *UsageStatus `json:"usage,omitempty"`
+
+	// AutoExpansion is the record of the automatic expansions of the backing
+	// storage claim
+	// +optional
+	AutoExpansion *AutoExpansionStatus `json:"autoExpansion,omitempty"`
+
+	// ExtraResources are the extra resources applied by the operator, the ones
+	// removed from the spec are deleted
+	// +optional
+	ExtraResources []ExtraResourceReference `json:"extraResources,omitempty"`
+
+	// Conditions is the list of the latest available observations of the Nfs
+	// +optional
+	Conditions status.Conditions `json:"conditions,omitempty"`
 }
 
+// Condition types reported on the Nfs status
+const (
+	// ConditionBackingStorageBound is True when the backing storage claim is bound
+	ConditionBackingStorageBound status.ConditionType = "BackingStorageBound"
+	// ConditionProvisionerReady is True when the NFS provisioner is available
+	ConditionProvisionerReady status.ConditionType = "ProvisionerReady"
+	// ConditionStorageClassReady is True when the StorageClass exists
+	ConditionStorageClassReady status.ConditionType = "StorageClassReady"
+	// ConditionReady is True when all the ReadyConditions are True
+	ConditionReady status.ConditionType = "Ready"
+	// ConditionBackingStorageResized is True when the backing storage has the
+	// requested size, it's False while it's resizing or the resize is rejected
+	ConditionBackingStorageResized status.ConditionType = "BackingStorageResized"
+	// ConditionDefaultStorageClass is True when the StorageClass is the cluster
+	// default, it's False if it's refused because other StorageClass is the
+	// default. It's set only if the Nfs requests a default StorageClass
+	ConditionDefaultStorageClass status.ConditionType = "DefaultStorageClass"
+	// ConditionStorageUsageHigh is True when the used space or inodes of the
+	// exported volume reach the usage warning threshold. It's set only if the Nfs
+	// has a warning threshold
+	ConditionStorageUsageHigh status.ConditionType = "StorageUsageHigh"
+)
+
+// ReadyConditions are the conditions required to be True to have a Ready Nfs
+var ReadyConditions = []status.ConditionType{
+	ConditionBackingStorageBound,
+	ConditionProvisionerReady,
+	ConditionStorageClassReady,
+}
+
+// Phases reported on the Nfs status
+const (
+	PhasePending = "Pending"
+	PhaseReady   = "Ready"
+	PhaseFailed  = "Failed"
+)
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // Nfs is the Schema for the nfs API
 // +kubebuilder:subresource:status
+// +kubebuilder:storageversion
 // +kubebuilder:resource:path=nfs,scope=Namespaced
 // +kubebuilder:printcolumn:JSONPath=".status.capacity",name=Capacity,type=string
 // +kubebuilder:printcolumn:JSONPath=".spec.storageclass",name=StorageClass,type=string
+// +kubebuilder:printcolumn:JSONPath=".status.status",name=Status,type=string
+// +kubebuilder:printcolumn:JSONPath=".status.conditions[?(@.type==\"Ready\")].status",name=Ready,type=string
 type Nfs struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
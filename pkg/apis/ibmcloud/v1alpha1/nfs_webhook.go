@@ -0,0 +1,657 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-ibmcloud-ibm-com-v1alpha1-nfs,mutating=true,failurePolicy=fail,groups=ibmcloud.ibm.com,resources=nfs,versions=v1alpha1,name=mnfs.v1alpha1.ibmcloud.ibm.com
+// +kubebuilder:webhook:verbs=create;update,path=/validate-ibmcloud-ibm-com-v1alpha1-nfs,mutating=false,failurePolicy=fail,groups=ibmcloud.ibm.com,resources=nfs,versions=v1alpha1,name=vnfs.ibmcloud.ibm.com
+
+var _ webhook.Defaulter = &Nfs{}
+var _ webhook.Validator = &Nfs{}
+
+// SetupWebhookWithManager registers the defaulting, validating and conversion
+// webhooks of the Nfs in the webhook server of the manager
+func (r *Nfs) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// Default implements webhook.Defaulter, it sets the defaults of the fields
+// that are not computed from the Nfs name by the controller
+func (r *Nfs) Default() {
+	if len(r.Spec.BackingStorage.Type) == 0 {
+		r.Spec.BackingStorage.Type = BackendVPCBlock
+	}
+	if len(r.Spec.DeletionPolicy) == 0 {
+		r.Spec.DeletionPolicy = DeletionPolicyDelete
+	}
+}
+
+// ValidateCreate implements webhook.Validator, in addition to the spec
+// validation it rejects a name that cannot be used in the names of the
+// resources. The names of the resources of an existing Nfs are already set
+func (r *Nfs) ValidateCreate() error {
+	return r.invalid(append(r.validateName(), r.validateSpec()...))
+}
+
+// ValidateUpdate implements webhook.Validator, in addition to the spec
+// validation it rejects changes to the immutable fields. The spec is validated
+// only if it changes, the operator settings (i.e. the allowed extra kinds) may
+// have changed since it was accepted and the Nfs, like its finalizer, has to be
+// updated anyway. A Nfs being deleted is not validated
+func (r *Nfs) ValidateUpdate(old runtime.Object) error {
+	if r.GetDeletionTimestamp() != nil {
+		return nil
+	}
+	oldNfs, ok := old.(*Nfs)
+	if !ok {
+		return r.Validate()
+	}
+	if reflect.DeepEqual(oldNfs.Spec, r.Spec) {
+		return r.invalid(r.validateImmutable(oldNfs))
+	}
+	return r.invalid(append(r.validateSpec(), r.validateImmutable(oldNfs)...))
+}
+
+// ValidateDelete implements webhook.Validator, a Nfs can always be deleted
+func (r *Nfs) ValidateDelete() error {
+	return nil
+}
+
+// Validate returns an Invalid API error if the Nfs spec is not valid. It's used
+// by the webhook and by the controller, for the Nfs created without the webhook
+func (r *Nfs) Validate() error {
+	return r.invalid(r.validateSpec())
+}
+
+// AppNameSuffix is the suffix of the Nfs name in the name of the NFS
+// provisioner resources, like its Service
+const AppNameSuffix = "-nfs-provisioner"
+
+// validateName returns the errors found in the Nfs name. The name of the NFS
+// provisioner Service has to be a DNS-1035 label, and the default provisioner
+// name has to be a qualified name
+func (r *Nfs) validateName() field.ErrorList {
+	errs := field.ErrorList{}
+	namePath := field.NewPath("metadata", "name")
+
+	appName := r.Name + AppNameSuffix
+	for _, msg := range validation.IsDNS1035Label(appName) {
+		errs = append(errs, field.Invalid(namePath, r.Name, fmt.Sprintf("invalid name %s of the NFS provisioner Service: %s", appName, msg)))
+	}
+	if len(r.Spec.ProvisionerAPI) == 0 {
+		provisioner := SchemeGroupVersion.Group + "/" + r.Namespace + "-" + r.Name
+		for _, msg := range validation.IsQualifiedName(provisioner) {
+			errs = append(errs, field.Invalid(namePath, r.Name, fmt.Sprintf("invalid provisioner name %s, set the provisionerAPI: %s", provisioner, msg)))
+		}
+	}
+
+	return errs
+}
+
+// validateSpec returns the list of errors found in the Nfs spec
+func (r *Nfs) validateSpec() field.ErrorList {
+	errs := field.ErrorList{}
+	specPath := field.NewPath("spec")
+
+	if sc := r.Spec.StorageClass; len(sc) != 0 {
+		for _, msg := range validation.IsDNS1123Subdomain(sc) {
+			errs = append(errs, field.Invalid(specPath.Child("storageClass"), sc, msg))
+		}
+	}
+
+	// same validation the API server applies to the StorageClass provisioner
+	if p := r.Spec.ProvisionerAPI; len(p) != 0 {
+		for _, msg := range validation.IsQualifiedName(strings.ToLower(p)) {
+			errs = append(errs, field.Invalid(specPath.Child("provisionerAPI"), p, msg))
+		}
+	}
+
+	errs = append(errs, validateStorageClassTemplate(specPath.Child("storageClassTemplate"), r.Spec.StorageClassTemplate)...)
+	errs = append(errs, r.validateStorageClassProfiles(specPath)...)
+
+	backingPath := specPath.Child("backingStorage")
+	bs := r.Spec.BackingStorage
+
+	if len(bs.PvcName) != 0 {
+		for _, msg := range validation.IsDNS1123Subdomain(bs.PvcName) {
+			errs = append(errs, field.Invalid(backingPath.Child("pvcName"), bs.PvcName, msg))
+		}
+	}
+
+	if len(bs.StorageClass) != 0 {
+		for _, msg := range validation.IsDNS1123Subdomain(bs.StorageClass) {
+			errs = append(errs, field.Invalid(backingPath.Child("storageClass"), bs.StorageClass, msg))
+		}
+	}
+
+	if len(bs.StorageSize) != 0 {
+		size, err := resource.ParseQuantity(bs.StorageSize)
+		if err != nil {
+			errs = append(errs, field.Invalid(backingPath.Child("storageSize"), bs.StorageSize, err.Error()))
+		} else if size.Sign() <= 0 {
+			errs = append(errs, field.Invalid(backingPath.Child("storageSize"), bs.StorageSize, "must be greater than zero"))
+		}
+	}
+
+	if len(bs.HostPath) != 0 && !filepath.IsAbs(bs.HostPath) {
+		errs = append(errs, field.Invalid(backingPath.Child("hostPath"), bs.HostPath, "must be an absolute path"))
+	}
+
+	if bs.AutoExpand != nil {
+		errs = append(errs, r.validateAutoExpand(backingPath.Child("autoExpand"), *bs.AutoExpand)...)
+	}
+
+	errs = append(errs, validateExports(specPath.Child("exports"), r.Spec.Exports)...)
+
+	provisionerPath := specPath.Child("provisioner")
+	if img := r.Spec.Provisioner.Image; len(img) != 0 {
+		for _, msg := range IsImageReference(img) {
+			errs = append(errs, field.Invalid(provisionerPath.Child("image"), img, msg))
+		}
+	}
+	for i, secret := range r.Spec.Provisioner.ImagePullSecrets {
+		for _, msg := range validation.IsDNS1123Subdomain(secret.Name) {
+			errs = append(errs, field.Invalid(provisionerPath.Child("imagePullSecrets").Index(i).Child("name"), secret.Name, msg))
+		}
+	}
+
+	errs = append(errs, validatePodTemplate(specPath.Child("provisioner", "podTemplate"), r.Spec.Provisioner.PodTemplate)...)
+	errs = append(errs, validateProbe(provisionerPath.Child("probes", "liveness"), r.Spec.Provisioner.Probes.Liveness)...)
+	errs = append(errs, validateProbe(provisionerPath.Child("probes", "readiness"), r.Spec.Provisioner.Probes.Readiness)...)
+	errs = append(errs, validateUsage(specPath.Child("usage"), r.Spec.Usage)...)
+
+	for i, raw := range r.Spec.ExtraResources {
+		errs = append(errs, validateExtraResource(specPath.Child("extraResources").Index(i), raw)...)
+	}
+
+	return errs
+}
+
+// IsDefaultClassAnnotation marks a StorageClass as the cluster default, the
+// beta annotation is still honored by the API server
+const (
+	IsDefaultClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	BetaIsDefaultClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+// validateStorageClassTemplate returns the errors found in the settings of the
+// StorageClass. The default class annotations are set only by the operator,
+// with the default field, to refuse a second default StorageClass
+func validateStorageClassTemplate(path *field.Path, sc StorageClassTemplateSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	errs = append(errs, metav1validation.ValidateLabels(sc.Labels, path.Child("labels"))...)
+	errs = append(errs, apivalidation.ValidateAnnotations(sc.Annotations, path.Child("annotations"))...)
+	for _, key := range []string{IsDefaultClassAnnotation, BetaIsDefaultClassAnnotation} {
+		if _, ok := sc.Annotations[key]; ok {
+			errs = append(errs, field.Forbidden(path.Child("annotations").Key(key), "use the default field to mark the StorageClass as the cluster default"))
+		}
+	}
+
+	for key := range sc.Parameters {
+		if len(key) == 0 {
+			errs = append(errs, field.Invalid(path.Child("parameters"), key, "must not have empty keys"))
+		}
+	}
+
+	for i, opt := range sc.MountOptions {
+		if len(strings.TrimSpace(opt)) == 0 {
+			errs = append(errs, field.Required(path.Child("mountOptions").Index(i), "must not be empty"))
+		}
+	}
+
+	if p := sc.ReclaimPolicy; p != nil && *p != corev1.PersistentVolumeReclaimDelete && *p != corev1.PersistentVolumeReclaimRetain {
+		errs = append(errs, field.NotSupported(path.Child("reclaimPolicy"), *p, []string{string(corev1.PersistentVolumeReclaimDelete), string(corev1.PersistentVolumeReclaimRetain)}))
+	}
+
+	if m := sc.VolumeBindingMode; m != nil && *m != storagev1.VolumeBindingImmediate && *m != storagev1.VolumeBindingWaitForFirstConsumer {
+		errs = append(errs, field.NotSupported(path.Child("volumeBindingMode"), *m, []string{string(storagev1.VolumeBindingImmediate), string(storagev1.VolumeBindingWaitForFirstConsumer)}))
+	}
+
+	return errs
+}
+
+// validateStorageClassProfiles returns the errors found in the StorageClass
+// profiles. Their names have to be unique and other than the Nfs StorageClass,
+// and only one StorageClass can be the cluster default
+func (r *Nfs) validateStorageClassProfiles(specPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	path := specPath.Child("storageClassProfiles")
+
+	// same default name of the StorageClass set by the controller
+	storageClass := r.Spec.StorageClass
+	if len(storageClass) == 0 {
+		storageClass = r.Namespace + "-" + r.Name
+	}
+
+	names := sets.NewString()
+	defaults := 0
+	if r.Spec.StorageClassTemplate.Default {
+		defaults++
+	}
+	for i, p := range r.Spec.StorageClassProfiles {
+		profilePath := path.Index(i)
+		switch {
+		case len(p.Name) == 0:
+			errs = append(errs, field.Required(profilePath.Child("name"), ""))
+		case p.Name == storageClass:
+			errs = append(errs, field.Invalid(profilePath.Child("name"), p.Name, "must be other than the storageClass of the Nfs"))
+		case names.Has(p.Name):
+			errs = append(errs, field.Duplicate(profilePath.Child("name"), p.Name))
+		default:
+			for _, msg := range validation.IsDNS1123Subdomain(p.Name) {
+				errs = append(errs, field.Invalid(profilePath.Child("name"), p.Name, msg))
+			}
+		}
+		names.Insert(p.Name)
+
+		errs = append(errs, validateStorageClassTemplate(profilePath, p.StorageClassTemplateSpec)...)
+
+		if p.Default {
+			defaults++
+			if defaults > 1 {
+				errs = append(errs, field.Invalid(profilePath.Child("default"), p.Default, "only one StorageClass can be the cluster default"))
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateExports returns the errors found in the export options, the values
+// rendered in the NFS server configuration
+func validateExports(path *field.Path, exports ExportsSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	anonymous := []struct {
+		name string
+		id   *int64
+	}{
+		{"anonymousUID", exports.AnonymousUID},
+		{"anonymousGID", exports.AnonymousGID},
+	}
+	for _, a := range anonymous {
+		if a.id == nil {
+			continue
+		}
+		if *a.id < 0 || *a.id > math.MaxUint32 {
+			errs = append(errs, field.Invalid(path.Child(a.name), *a.id, "must be between 0 and 4294967295"))
+		}
+		if len(exports.Squash) == 0 || exports.Squash == ExportSquashNone {
+			errs = append(errs, field.Invalid(path.Child(a.name), *a.id, "requires squash Root or All, the anonymous user is only used by squashed users"))
+		}
+	}
+
+	for i, c := range exports.AllowedClients {
+		if net.ParseIP(c) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(c); err != nil {
+			errs = append(errs, field.Invalid(path.Child("allowedClients").Index(i), c, "must be an IP address or a CIDR"))
+		}
+	}
+
+	versions := map[NFSVersion]bool{}
+	for i, v := range exports.NFSVersions {
+		if v != 3 && v != 4 {
+			errs = append(errs, field.NotSupported(path.Child("nfsVersions").Index(i), v, []string{"3", "4"}))
+		}
+		if versions[v] {
+			errs = append(errs, field.Duplicate(path.Child("nfsVersions").Index(i), v))
+		}
+		versions[v] = true
+	}
+
+	return errs
+}
+
+// imageRegexp is the grammar of an image reference, [domain/]path[:tag][@digest],
+// defined by github.com/docker/distribution/reference
+var imageRegexp = regexp.MustCompile(`^` +
+	`(?:(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*)*` +
+	`(?::([\w][\w.-]{0,127}))?` +
+	`(?:@([A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}))?` +
+	`$`)
+
+// IsImageReference returns a list of errors if the value is not an image
+// reference with a tag or a digest. An image without them is the mutable tag
+// latest, so it's not accepted
+func IsImageReference(value string) []string {
+	m := imageRegexp.FindStringSubmatch(value)
+	if m == nil {
+		return []string{"must be an image reference like registry/repository:tag or registry/repository@sha256:digest"}
+	}
+	if len(m[1]) == 0 && len(m[2]) == 0 {
+		return []string{"must have a tag or a digest"}
+	}
+	return nil
+}
+
+// reservedEnv are the environment variables of the NFS provisioner container
+// set by the operator, they cannot be replaced by the podTemplate env
+var reservedEnv = sets.NewString("POD_IP", "SERVICE_NAME", "POD_NAMESPACE")
+
+// validatePodTemplate returns the errors found in the settings of the NFS
+// provisioner Pod. These are the errors the API server would return when the
+// Deployment is applied, found before the Deployment is created
+func validatePodTemplate(path *field.Path, pt PodTemplateSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	errs = append(errs, apivalidation.ValidateAnnotations(pt.Annotations, path.Child("annotations"))...)
+	errs = append(errs, metav1validation.ValidateLabels(pt.NodeSelector, path.Child("nodeSelector"))...)
+
+	for i, t := range pt.Tolerations {
+		errs = append(errs, validateToleration(path.Child("tolerations").Index(i), t)...)
+	}
+
+	if len(pt.PriorityClassName) != 0 {
+		for _, msg := range validation.IsDNS1123Subdomain(pt.PriorityClassName) {
+			errs = append(errs, field.Invalid(path.Child("priorityClassName"), pt.PriorityClassName, msg))
+		}
+	}
+
+	resourcesPath := path.Child("resources")
+	for name, q := range pt.Resources.Limits {
+		if q.Sign() < 0 {
+			errs = append(errs, field.Invalid(resourcesPath.Child("limits").Key(string(name)), q.String(), "must be greater than or equal to 0"))
+		}
+	}
+	for name, q := range pt.Resources.Requests {
+		if q.Sign() < 0 {
+			errs = append(errs, field.Invalid(resourcesPath.Child("requests").Key(string(name)), q.String(), "must be greater than or equal to 0"))
+			continue
+		}
+		if limit, ok := pt.Resources.Limits[name]; ok && q.Cmp(limit) > 0 {
+			errs = append(errs, field.Invalid(resourcesPath.Child("requests").Key(string(name)), q.String(), "must be less than or equal to "+string(name)+" limit"))
+		}
+	}
+
+	names := sets.NewString()
+	for i, env := range pt.Env {
+		envPath := path.Child("env").Index(i)
+		for _, msg := range validation.IsEnvVarName(env.Name) {
+			errs = append(errs, field.Invalid(envPath.Child("name"), env.Name, msg))
+		}
+		if reservedEnv.Has(env.Name) {
+			errs = append(errs, field.Forbidden(envPath.Child("name"), "the variable "+env.Name+" is set by the operator"))
+		}
+		if names.Has(env.Name) {
+			errs = append(errs, field.Duplicate(envPath.Child("name"), env.Name))
+		}
+		names.Insert(env.Name)
+		if len(env.Value) != 0 && env.ValueFrom != nil {
+			errs = append(errs, field.Invalid(envPath.Child("valueFrom"), "", "may not be specified when `value` is not empty"))
+		}
+	}
+
+	return errs
+}
+
+// validateProbe returns the errors found in the settings of a probe of the NFS
+// server, the same limits the API server applies to a container probe
+func validateProbe(path *field.Path, p ProbeSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if len(p.Type) != 0 && p.Type != ProbeRPC && p.Type != ProbeTCP {
+		errs = append(errs, field.NotSupported(path.Child("type"), p.Type, []string{string(ProbeRPC), string(ProbeTCP)}))
+	}
+
+	if p.InitialDelaySeconds != nil && *p.InitialDelaySeconds < 0 {
+		errs = append(errs, field.Invalid(path.Child("initialDelaySeconds"), *p.InitialDelaySeconds, "must be greater than or equal to 0"))
+	}
+	positive := []struct {
+		name  string
+		value *int32
+	}{
+		{"periodSeconds", p.PeriodSeconds},
+		{"timeoutSeconds", p.TimeoutSeconds},
+		{"failureThreshold", p.FailureThreshold},
+	}
+	for _, f := range positive {
+		if f.value != nil && *f.value < 1 {
+			errs = append(errs, field.Invalid(path.Child(f.name), *f.value, "must be greater than or equal to 1"))
+		}
+	}
+
+	return errs
+}
+
+// validateAutoExpand returns the errors found in the autoExpand policy. Only a
+// claim created by the operator is expanded, and it's expanded on the
+// filesystem usage
+func (r *Nfs) validateAutoExpand(path *field.Path, ae AutoExpandSpec) field.ErrorList {
+	errs := field.ErrorList{}
+	bs := r.Spec.BackingStorage
+
+	if len(bs.Type) != 0 && bs.Type != BackendVPCBlock && bs.Type != BackendPVC {
+		errs = append(errs, field.Forbidden(path, fmt.Sprintf("the backing storage type %s has no claim to expand", bs.Type)))
+	}
+	if len(bs.StorageSize) == 0 {
+		errs = append(errs, field.Forbidden(path, "the claim is expanded only if it's created by the operator, it requires the storageSize"))
+	}
+	if r.Spec.Usage.Disabled {
+		errs = append(errs, field.Forbidden(path, "the claim is expanded on the filesystem usage, it cannot be disabled"))
+	} else if !UsageCollection {
+		errs = append(errs, field.Forbidden(path, "the claim is expanded on the filesystem usage, it's not collected by the operator"))
+	}
+
+	if ae.TriggerPercent < 1 || ae.TriggerPercent > 100 {
+		errs = append(errs, field.Invalid(path.Child("triggerPercent"), ae.TriggerPercent, "must be between 1 and 100"))
+	}
+	if step, err := resource.ParseQuantity(ae.Step); err != nil {
+		errs = append(errs, field.Invalid(path.Child("step"), ae.Step, err.Error()))
+	} else if step.Sign() <= 0 {
+		errs = append(errs, field.Invalid(path.Child("step"), ae.Step, "must be greater than zero"))
+	}
+	if maxSize, err := resource.ParseQuantity(ae.MaxSize); err != nil {
+		errs = append(errs, field.Invalid(path.Child("maxSize"), ae.MaxSize, err.Error()))
+	} else if size, err := resource.ParseQuantity(bs.StorageSize); err == nil && maxSize.Cmp(size) < 0 {
+		errs = append(errs, field.Invalid(path.Child("maxSize"), ae.MaxSize, "must be greater than or equal to the storageSize"))
+	}
+	if ae.Cooldown != nil && ae.Cooldown.Duration < 0 {
+		errs = append(errs, field.Invalid(path.Child("cooldown"), ae.Cooldown.Duration.String(), "must not be negative"))
+	}
+
+	return errs
+}
+
+// MinUsageInterval is the minimum time between collections of the filesystem
+// usage, every collection requests the volume stats to the kubelet
+const MinUsageInterval = 10 * time.Second
+
+// UsageCollection is true if the operator collects the filesystem usage. The
+// kubelet stats are requested through the API server proxy of the nodes, a
+// permission that gives full access to the kubelets, so the cluster admin has
+// to enable it
+var UsageCollection bool
+
+// validateUsage returns the errors found in the settings of the report of the
+// filesystem usage
+func validateUsage(path *field.Path, u UsageSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if u.Interval != nil && u.Interval.Duration < MinUsageInterval {
+		errs = append(errs, field.Invalid(path.Child("interval"), u.Interval.Duration.String(), fmt.Sprintf("must be greater than or equal to %s", MinUsageInterval)))
+	}
+	if t := u.WarningThreshold; t != nil && (*t < 1 || *t > 100) {
+		errs = append(errs, field.Invalid(path.Child("warningThreshold"), *t, "must be between 1 and 100"))
+	}
+
+	return errs
+}
+
+// validateToleration returns the errors found in a toleration of the NFS
+// provisioner Pod
+func validateToleration(path *field.Path, t corev1.Toleration) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if len(t.Key) != 0 {
+		errs = append(errs, metav1validation.ValidateLabelName(t.Key, path.Child("key"))...)
+	}
+
+	switch t.Operator {
+	case corev1.TolerationOpEqual, "":
+		if len(t.Key) == 0 {
+			errs = append(errs, field.Invalid(path.Child("operator"), t.Operator, "operator must be Exists when `key` is empty"))
+		}
+		for _, msg := range validation.IsValidLabelValue(t.Value) {
+			errs = append(errs, field.Invalid(path.Child("value"), t.Value, msg))
+		}
+	case corev1.TolerationOpExists:
+		if len(t.Value) != 0 {
+			errs = append(errs, field.Invalid(path.Child("value"), t.Value, "value must be empty when `operator` is 'Exists'"))
+		}
+	default:
+		errs = append(errs, field.NotSupported(path.Child("operator"), t.Operator, []string{string(corev1.TolerationOpEqual), string(corev1.TolerationOpExists)}))
+	}
+
+	switch t.Effect {
+	case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+	default:
+		errs = append(errs, field.NotSupported(path.Child("effect"), t.Effect, []string{string(corev1.TaintEffectNoSchedule), string(corev1.TaintEffectPreferNoSchedule), string(corev1.TaintEffectNoExecute)}))
+	}
+
+	if t.TolerationSeconds != nil && t.Effect != corev1.TaintEffectNoExecute {
+		errs = append(errs, field.Invalid(path.Child("effect"), t.Effect, "effect must be 'NoExecute' when `tolerationSeconds` is set"))
+	}
+
+	return errs
+}
+
+// AllowedExtraKinds are the kinds, as Kind.group or Kind for the core group,
+// allowed in the extra resources. They are applied with the permissions of the
+// operator, so the cluster admin decides which ones the Nfs authors can create,
+// none by default
+var AllowedExtraKinds []string
+
+// IsAllowedExtraKind returns true if the kind is in the AllowedExtraKinds
+func IsAllowedExtraKind(gk schema.GroupKind) bool {
+	for _, kind := range AllowedExtraKinds {
+		if schema.ParseGroupKind(strings.TrimSpace(kind)) == gk {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtraKindNotAllowed returns the error of an extra resource with a kind that
+// is not in the AllowedExtraKinds
+func ExtraKindNotAllowed(path *field.Path, gk schema.GroupKind) *field.Error {
+	allowed := "no kind is allowed"
+	if len(AllowedExtraKinds) != 0 {
+		allowed = "the allowed kinds are " + strings.Join(AllowedExtraKinds, ", ")
+	}
+	return field.Forbidden(path.Child("kind"), fmt.Sprintf("the kind %s is not allowed in the extra resources, %s", gk, allowed))
+}
+
+// validateExtraResource returns the errors found in a manifest of an extra
+// resource. It's a template rendered by the controller, so only the template
+// syntax, the fields required to identify the resource and its kind are
+// validated
+func validateExtraResource(path *field.Path, raw runtime.RawExtension) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if _, err := template.New(path.String()).Parse(string(raw.Raw)); err != nil {
+		return append(errs, field.Invalid(path, string(raw.Raw), err.Error()))
+	}
+
+	manifest := struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Metadata   struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(raw.Raw, &manifest); err != nil {
+		return append(errs, field.Invalid(path, string(raw.Raw), err.Error()))
+	}
+	if len(manifest.APIVersion) == 0 {
+		errs = append(errs, field.Required(path.Child("apiVersion"), ""))
+	}
+	if len(manifest.Kind) == 0 {
+		errs = append(errs, field.Required(path.Child("kind"), ""))
+	}
+	if len(manifest.Metadata.Name) == 0 {
+		errs = append(errs, field.Required(path.Child("metadata", "name"), ""))
+	}
+	if len(manifest.APIVersion) != 0 && len(manifest.Kind) != 0 {
+		gv, err := schema.ParseGroupVersion(manifest.APIVersion)
+		if err != nil {
+			errs = append(errs, field.Invalid(path.Child("apiVersion"), manifest.APIVersion, err.Error()))
+		} else if gk := gv.WithKind(manifest.Kind).GroupKind(); !IsAllowedExtraKind(gk) {
+			errs = append(errs, ExtraKindNotAllowed(path, gk))
+		}
+	}
+
+	return errs
+}
+
+// validateImmutable returns an error for every field that cannot change after
+// the Nfs is created because the resources created from it cannot be updated
+func (r *Nfs) validateImmutable(old *Nfs) field.ErrorList {
+	errs := field.ErrorList{}
+	specPath := field.NewPath("spec")
+	backingPath := specPath.Child("backingStorage")
+
+	immutable := []struct {
+		path     *field.Path
+		old, new string
+	}{
+		{specPath.Child("storageClass"), old.Spec.StorageClass, r.Spec.StorageClass},
+		{specPath.Child("provisionerAPI"), old.Spec.ProvisionerAPI, r.Spec.ProvisionerAPI},
+		{backingPath.Child("type"), string(old.Spec.BackingStorage.Type), string(r.Spec.BackingStorage.Type)},
+		{backingPath.Child("pvcName"), old.Spec.BackingStorage.PvcName, r.Spec.BackingStorage.PvcName},
+		{backingPath.Child("storageClass"), old.Spec.BackingStorage.StorageClass, r.Spec.BackingStorage.StorageClass},
+	}
+
+	for _, f := range immutable {
+		if f.old != f.new {
+			errs = append(errs, field.Forbidden(f.path, "field is immutable"))
+		}
+	}
+
+	// the claim is only expanded, a decrease would never be applied
+	oldSize, oldErr := resource.ParseQuantity(old.Spec.BackingStorage.StorageSize)
+	size, err := resource.ParseQuantity(r.Spec.BackingStorage.StorageSize)
+	if oldErr == nil && err == nil && size.Cmp(oldSize) < 0 {
+		errs = append(errs, field.Forbidden(backingPath.Child("storageSize"), fmt.Sprintf("the claim cannot shrink, it must be greater than or equal to %s", oldSize.String())))
+	}
+
+	return errs
+}
+
+// invalid returns an Invalid API error with the given list of errors, or nil
+// if the list is empty
+func (r *Nfs) invalid(errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(SchemeGroupVersion.WithKind("Nfs").GroupKind(), r.Name, errs)
+}
@@ -0,0 +1,451 @@
+package v1alpha1
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// newValidNfs returns a Nfs with a valid spec
+func newValidNfs() *Nfs {
+	return &Nfs{
+		ObjectMeta: metav1.ObjectMeta{Name: "nfs", Namespace: "test"},
+		Spec: NfsSpec{
+			BackingStorage: BackingStorageSpec{
+				Type:        BackendVPCBlock,
+				StorageSize: "10Gi",
+			},
+		},
+	}
+}
+
+// fieldPaths returns the sorted paths of the fields with errors
+func fieldPaths(errs field.ErrorList) []string {
+	paths := []string{}
+	for _, e := range errs {
+		paths = append(paths, e.Field)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func int32Ptr(i int32) *int32 { return &i }
+func int64Ptr(i int64) *int64 { return &i }
+
+func TestValidateSpec(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(r *Nfs)
+		want   []string
+	}{
+		{
+			name:   "valid",
+			modify: func(r *Nfs) {},
+			want:   []string{},
+		},
+		{
+			name: "invalid names",
+			modify: func(r *Nfs) {
+				r.Spec.StorageClass = "Invalid_Name"
+				r.Spec.ProvisionerAPI = "example.com/nfs/invalid"
+				r.Spec.BackingStorage.PvcName = "-claim"
+				r.Spec.BackingStorage.StorageClass = "block_storage"
+			},
+			want: []string{"spec.backingStorage.pvcName", "spec.backingStorage.storageClass", "spec.provisionerAPI", "spec.storageClass"},
+		},
+		{
+			name:   "invalid storage size",
+			modify: func(r *Nfs) { r.Spec.BackingStorage.StorageSize = "10GB" },
+			want:   []string{"spec.backingStorage.storageSize"},
+		},
+		{
+			name:   "zero storage size",
+			modify: func(r *Nfs) { r.Spec.BackingStorage.StorageSize = "0" },
+			want:   []string{"spec.backingStorage.storageSize"},
+		},
+		{
+			name:   "relative host path",
+			modify: func(r *Nfs) { r.Spec.BackingStorage.HostPath = "data/nfs" },
+			want:   []string{"spec.backingStorage.hostPath"},
+		},
+		{
+			name: "default class annotation",
+			modify: func(r *Nfs) {
+				r.Spec.StorageClassTemplate.Annotations = map[string]string{IsDefaultClassAnnotation: "true"}
+			},
+			want: []string{"spec.storageClassTemplate.annotations[storageclass.kubernetes.io/is-default-class]"},
+		},
+		{
+			name: "invalid profiles",
+			modify: func(r *Nfs) {
+				r.Spec.StorageClassTemplate.Default = true
+				r.Spec.StorageClassProfiles = []StorageClassProfile{
+					{Name: "test-nfs"},
+					{Name: "fast", StorageClassTemplateSpec: StorageClassTemplateSpec{Default: true}},
+					{Name: "fast"},
+					{},
+				}
+			},
+			want: []string{"spec.storageClassProfiles[0].name", "spec.storageClassProfiles[1].default", "spec.storageClassProfiles[2].name", "spec.storageClassProfiles[3].name"},
+		},
+		{
+			name: "valid exports",
+			modify: func(r *Nfs) {
+				r.Spec.Exports = ExportsSpec{
+					Access:         ExportReadOnly,
+					Squash:         ExportSquashAll,
+					AnonymousUID:   int64Ptr(65534),
+					AnonymousGID:   int64Ptr(65534),
+					AllowedClients: []string{"10.0.0.1", "10.1.0.0/16"},
+					NFSVersions:    []NFSVersion{3, 4},
+				}
+			},
+			want: []string{},
+		},
+		{
+			name: "invalid exports",
+			modify: func(r *Nfs) {
+				r.Spec.Exports = ExportsSpec{
+					AnonymousUID:   int64Ptr(-1),
+					AllowedClients: []string{"example.com"},
+					NFSVersions:    []NFSVersion{4, 2, 4},
+				}
+			},
+			want: []string{"spec.exports.allowedClients[0]", "spec.exports.anonymousUID", "spec.exports.anonymousUID", "spec.exports.nfsVersions[1]", "spec.exports.nfsVersions[2]"},
+		},
+		{
+			name: "invalid provisioner",
+			modify: func(r *Nfs) {
+				r.Spec.Provisioner.Image = "quay.io/nfs-provisioner"
+				r.Spec.Provisioner.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "Secret"}}
+				r.Spec.Provisioner.PodTemplate.Env = []corev1.EnvVar{{Name: "POD_IP", Value: "1.2.3.4"}}
+				r.Spec.Provisioner.PodTemplate.Resources.Requests = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}
+				r.Spec.Provisioner.PodTemplate.Resources.Limits = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}
+				r.Spec.Provisioner.Probes.Liveness.PeriodSeconds = int32Ptr(0)
+			},
+			want: []string{
+				"spec.provisioner.image",
+				"spec.provisioner.imagePullSecrets[0].name",
+				"spec.provisioner.podTemplate.env[0].name",
+				"spec.provisioner.podTemplate.resources.requests[cpu]",
+				"spec.provisioner.probes.liveness.periodSeconds",
+			},
+		},
+		{
+			name: "invalid usage",
+			modify: func(r *Nfs) {
+				r.Spec.Usage.Interval = &metav1.Duration{Duration: time.Second}
+				r.Spec.Usage.WarningThreshold = int32Ptr(101)
+			},
+			want: []string{"spec.usage.interval", "spec.usage.warningThreshold"},
+		},
+		{
+			name: "valid autoExpand",
+			modify: func(r *Nfs) {
+				r.Spec.BackingStorage.AutoExpand = &AutoExpandSpec{TriggerPercent: 80, Step: "5Gi", MaxSize: "10Gi"}
+			},
+			want: []string{},
+		},
+		{
+			name: "invalid autoExpand",
+			modify: func(r *Nfs) {
+				r.Spec.BackingStorage.AutoExpand = &AutoExpandSpec{
+					TriggerPercent: 0,
+					Step:           "-1Gi",
+					MaxSize:        "5Gi",
+					Cooldown:       &metav1.Duration{Duration: -time.Minute},
+				}
+			},
+			want: []string{"spec.backingStorage.autoExpand.cooldown", "spec.backingStorage.autoExpand.maxSize", "spec.backingStorage.autoExpand.step", "spec.backingStorage.autoExpand.triggerPercent"},
+		},
+		{
+			name: "autoExpand without claim to expand",
+			modify: func(r *Nfs) {
+				r.Spec.BackingStorage.Type = BackendEmptyDir
+				r.Spec.BackingStorage.StorageSize = ""
+				r.Spec.Usage.Disabled = true
+				r.Spec.BackingStorage.AutoExpand = &AutoExpandSpec{TriggerPercent: 80, Step: "5Gi", MaxSize: "10Gi"}
+			},
+			want: []string{"spec.backingStorage.autoExpand", "spec.backingStorage.autoExpand", "spec.backingStorage.autoExpand"},
+		},
+		{
+			name: "autoExpand without the usage collected by the operator",
+			modify: func(r *Nfs) {
+				UsageCollection = false
+				r.Spec.BackingStorage.AutoExpand = &AutoExpandSpec{TriggerPercent: 80, Step: "5Gi", MaxSize: "10Gi"}
+			},
+			want: []string{"spec.backingStorage.autoExpand"},
+		},
+		{
+			name: "invalid extra resource",
+			modify: func(r *Nfs) {
+				r.Spec.ExtraResources = []runtime.RawExtension{{Raw: []byte(`{"apiVersion":"v1","kind":"ConfigMap"}`)}}
+			},
+			want: []string{"spec.extraResources[0].metadata.name"},
+		},
+		{
+			name: "allowed extra resources",
+			modify: func(r *Nfs) {
+				r.Spec.ExtraResources = []runtime.RawExtension{
+					{Raw: []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings"}}`)},
+					{Raw: []byte(`{"apiVersion":"cert-manager.io/v1","kind":"Certificate","metadata":{"name":"tls"}}`)},
+				}
+			},
+			want: []string{},
+		},
+		{
+			name: "extra resource kind not allowed",
+			modify: func(r *Nfs) {
+				r.Spec.ExtraResources = []runtime.RawExtension{
+					{Raw: []byte(`{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"ClusterRoleBinding","metadata":{"name":"admin"}}`)},
+					{Raw: []byte(`{"apiVersion":"example.com/v1","kind":"ConfigMap","metadata":{"name":"settings"}}`)},
+				}
+			},
+			want: []string{"spec.extraResources[0].kind", "spec.extraResources[1].kind"},
+		},
+	}
+	defer func(kinds []string) { AllowedExtraKinds = kinds }(AllowedExtraKinds)
+	AllowedExtraKinds = []string{"ConfigMap", "Certificate.cert-manager.io"}
+	defer func(enabled bool) { UsageCollection = enabled }(UsageCollection)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			UsageCollection = true
+			r := newValidNfs()
+			tt.modify(r)
+			if got := fieldPaths(r.validateSpec()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateSpec() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCreateName(t *testing.T) {
+	tests := []struct {
+		name        string
+		nfsName     string
+		namespace   string
+		provisioner string
+		want        []string
+	}{
+		{
+			name:      "valid",
+			nfsName:   "nfs",
+			namespace: "test",
+			want:      []string{},
+		},
+		{
+			name:      "longest name",
+			nfsName:   strings.Repeat("a", 47),
+			namespace: "test",
+			want:      []string{},
+		},
+		{
+			name:      "name with dots",
+			nfsName:   "shared.nfs",
+			namespace: "test",
+			want:      []string{"metadata.name"},
+		},
+		{
+			name:      "name too long for the Service",
+			nfsName:   strings.Repeat("a", 48),
+			namespace: "test",
+			want:      []string{"metadata.name"},
+		},
+		{
+			name:      "name too long for the default provisioner",
+			nfsName:   strings.Repeat("a", 40),
+			namespace: strings.Repeat("b", 30),
+			want:      []string{"metadata.name"},
+		},
+		{
+			name:        "long names with a provisioner",
+			nfsName:     strings.Repeat("a", 40),
+			namespace:   strings.Repeat("b", 30),
+			provisioner: "example.com/nfs",
+			want:        []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newValidNfs()
+			r.Name = tt.nfsName
+			r.Namespace = tt.namespace
+			r.Spec.ProvisionerAPI = tt.provisioner
+			if got := fieldPaths(r.validateName()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateName() = %v, want %v", got, tt.want)
+			}
+			if err := r.ValidateCreate(); (err != nil) != (len(tt.want) != 0) {
+				t.Errorf("ValidateCreate() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(r *Nfs)
+		wantErr bool
+	}{
+		{
+			name:   "storage size grows",
+			modify: func(r *Nfs) { r.Spec.BackingStorage.StorageSize = "20Gi" },
+		},
+		{
+			name:    "storage size shrinks",
+			modify:  func(r *Nfs) { r.Spec.BackingStorage.StorageSize = "5Gi" },
+			wantErr: true,
+		},
+		{
+			name:   "storage size in other units",
+			modify: func(r *Nfs) { r.Spec.BackingStorage.StorageSize = "10240Mi" },
+		},
+		{
+			name:    "storage class",
+			modify:  func(r *Nfs) { r.Spec.StorageClass = "other" },
+			wantErr: true,
+		},
+		{
+			name:    "provisioner",
+			modify:  func(r *Nfs) { r.Spec.ProvisionerAPI = "example.com/other" },
+			wantErr: true,
+		},
+		{
+			name:    "backing storage type",
+			modify:  func(r *Nfs) { r.Spec.BackingStorage.Type = BackendPVC },
+			wantErr: true,
+		},
+		{
+			name:    "claim name",
+			modify:  func(r *Nfs) { r.Spec.BackingStorage.PvcName = "other" },
+			wantErr: true,
+		},
+		{
+			name:    "claim storage class",
+			modify:  func(r *Nfs) { r.Spec.BackingStorage.StorageClass = "other" },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := newValidNfs()
+			r := old.DeepCopy()
+			tt.modify(r)
+			if err := r.ValidateUpdate(old); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateNarrowedSettings(t *testing.T) {
+	defer func(kinds []string, enabled bool) {
+		AllowedExtraKinds, UsageCollection = kinds, enabled
+	}(AllowedExtraKinds, UsageCollection)
+
+	// accepted before the cluster admin narrowed the operator settings
+	old := newValidNfs()
+	old.Finalizers = []string{"nfs.ibmcloud.ibm.com/finalizer"}
+	old.Spec.ExtraResources = []runtime.RawExtension{{Raw: []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings"}}`)}}
+	old.Spec.BackingStorage.AutoExpand = &AutoExpandSpec{TriggerPercent: 80, Step: "5Gi", MaxSize: "20Gi"}
+	AllowedExtraKinds = nil
+	UsageCollection = false
+
+	tests := []struct {
+		name    string
+		modify  func(r *Nfs)
+		wantErr bool
+	}{
+		{
+			name:   "finalizer removed",
+			modify: func(r *Nfs) { r.Finalizers = nil },
+		},
+		{
+			name:   "metadata changed",
+			modify: func(r *Nfs) { r.Annotations = map[string]string{"example.com/note": "updated"} },
+		},
+		{
+			name: "finalizer removed while deleted",
+			modify: func(r *Nfs) {
+				now := metav1.Now()
+				r.DeletionTimestamp = &now
+				r.Finalizers = nil
+			},
+		},
+		{
+			name:    "spec changed",
+			modify:  func(r *Nfs) { r.Spec.BackingStorage.StorageSize = "20Gi" },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := old.DeepCopy()
+			tt.modify(r)
+			if err := r.ValidateUpdate(old); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefault(t *testing.T) {
+	r := &Nfs{}
+	r.Default()
+	if r.Spec.BackingStorage.Type != BackendVPCBlock || r.Spec.DeletionPolicy != DeletionPolicyDelete {
+		t.Errorf("Default() = %s, %s, want %s, %s", r.Spec.BackingStorage.Type, r.Spec.DeletionPolicy, BackendVPCBlock, DeletionPolicyDelete)
+	}
+}
+
+func TestIsAllowedExtraKind(t *testing.T) {
+	defer func(kinds []string) { AllowedExtraKinds = kinds }(AllowedExtraKinds)
+
+	tests := []struct {
+		name    string
+		allowed []string
+		kind    schema.GroupKind
+		want    bool
+	}{
+		{"none allowed by default", nil, schema.GroupKind{Kind: "ConfigMap"}, false},
+		{"core group", []string{"ConfigMap"}, schema.GroupKind{Kind: "ConfigMap"}, true},
+		{"other group", []string{"Certificate.cert-manager.io"}, schema.GroupKind{Group: "cert-manager.io", Kind: "Certificate"}, true},
+		{"same kind in other group", []string{"ConfigMap"}, schema.GroupKind{Group: "example.com", Kind: "ConfigMap"}, false},
+		{"spaces around", []string{" Secret ", "ConfigMap"}, schema.GroupKind{Kind: "Secret"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			AllowedExtraKinds = tt.allowed
+			if got := IsAllowedExtraKind(tt.kind); got != tt.want {
+				t.Errorf("IsAllowedExtraKind(%v) = %v, want %v", tt.kind, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsImageReference(t *testing.T) {
+	tests := []struct {
+		image string
+		valid bool
+	}{
+		{"quay.io/kubernetes_incubator/nfs-provisioner:v2.3.0", true},
+		{"registry:5000/nfs-provisioner@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", true},
+		{"nfs-provisioner:latest", true},
+		{"nfs-provisioner", false},
+		{"Quay.io/NFS:v1", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := len(IsImageReference(tt.image)) == 0; got != tt.valid {
+			t.Errorf("IsImageReference(%q) valid = %v, want %v", tt.image, got, tt.valid)
+		}
+	}
+}
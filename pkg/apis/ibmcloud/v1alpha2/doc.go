@@ -0,0 +1,4 @@
+// Package v1alpha2 contains API Schema definitions for the ibmcloud v1alpha2 API group
+// +k8s:deepcopy-gen=package,register
+// +groupName=ibmcloud.ibm.com
+package v1alpha2
@@ -0,0 +1,367 @@
+package v1alpha2
+
+import (
+	"encoding/json"
+
+	"github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// rawQuantitiesAnnotation keeps on this version the v1alpha1 sizes that are
+// not valid quantities, by their v1alpha1 path. The Nfs is still readable in
+// this version without them, and converted back to v1alpha1 they are restored
+// so the validation reports them
+const rawQuantitiesAnnotation = "ibmcloud.ibm.com/v1alpha1-raw-quantities"
+
+// Paths of the v1alpha1 sizes kept in the rawQuantitiesAnnotation
+const (
+	rawStorageSize = "backingStorage.storageSize"
+	rawStep        = "backingStorage.autoExpand.step"
+	rawMaxSize     = "backingStorage.autoExpand.maxSize"
+)
+
+var _ conversion.Convertible = &Nfs{}
+
+// ConvertTo converts this Nfs to the Hub version (v1alpha1)
+func (src *Nfs) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.Nfs)
+
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	raw := rawQuantities(dst.Annotations)
+	delete(dst.Annotations, rawQuantitiesAnnotation)
+
+	dst.Spec.StorageClass = src.Spec.StorageClass.Name
+	dst.Spec.ProvisionerAPI = src.Spec.StorageClass.Provisioner
+	dst.Spec.StorageClassTemplate = convertStorageClassSettingsTo(src.Spec.StorageClass.StorageClassSettings)
+	dst.Spec.StorageClassProfiles = nil
+	for _, p := range src.Spec.StorageClass.Profiles {
+		dst.Spec.StorageClassProfiles = append(dst.Spec.StorageClassProfiles, v1alpha1.StorageClassProfile{
+			Name:                     p.Name,
+			StorageClassTemplateSpec: convertStorageClassSettingsTo(p.StorageClassSettings),
+		})
+	}
+	dst.Spec.DeletionPolicy = v1alpha1.DeletionPolicy(src.Spec.DeletionPolicy)
+	dst.Spec.ExtraResources = copyRawExtensions(src.Spec.ExtraResources)
+
+	dst.Spec.BackingStorage = v1alpha1.BackingStorageSpec{
+		Type:         v1alpha1.BackendType(src.Spec.Backend.Type),
+		PvcName:      src.Spec.Backend.ClaimName,
+		StorageClass: src.Spec.Backend.StorageClass,
+		HostPath:     src.Spec.Backend.HostPath,
+	}
+	if src.Spec.Backend.Size != nil {
+		dst.Spec.BackingStorage.StorageSize = src.Spec.Backend.Size.String()
+	} else {
+		dst.Spec.BackingStorage.StorageSize = raw[rawStorageSize]
+	}
+	if ae := src.Spec.Backend.AutoExpand.DeepCopy(); ae != nil {
+		dst.Spec.BackingStorage.AutoExpand = &v1alpha1.AutoExpandSpec{
+			TriggerPercent: ae.TriggerPercent,
+			Step:           quantityString(ae.Step, raw[rawStep]),
+			MaxSize:        quantityString(ae.MaxSize, raw[rawMaxSize]),
+			Cooldown:       ae.Cooldown,
+		}
+	}
+
+	dst.Spec.Provisioner.Image = src.Spec.Image
+	dst.Spec.Provisioner.ImagePullSecrets = copyLocalObjectReferences(src.Spec.ImagePullSecrets)
+	dst.Spec.Provisioner.Probes = v1alpha1.ProbesSpec{
+		Liveness:  convertProbeTo(src.Spec.Probes.Liveness),
+		Readiness: convertProbeTo(src.Spec.Probes.Readiness),
+	}
+	usage := src.Spec.Usage.DeepCopy()
+	dst.Spec.Usage = v1alpha1.UsageSpec{
+		Disabled:         usage.Disabled,
+		Interval:         usage.Interval,
+		WarningThreshold: usage.WarningThreshold,
+	}
+	pod := src.Spec.Pod.DeepCopy()
+	dst.Spec.Provisioner.PodTemplate = v1alpha1.PodTemplateSpec{
+		Annotations:       pod.Annotations,
+		NodeSelector:      pod.NodeSelector,
+		Tolerations:       pod.Tolerations,
+		Affinity:          pod.Affinity,
+		PriorityClassName: pod.PriorityClassName,
+		Resources:         *src.Spec.Resources.DeepCopy(),
+		Env:               pod.Env,
+	}
+
+	export := src.Spec.Export.DeepCopy()
+	dst.Spec.Exports = v1alpha1.ExportsSpec{
+		Access:         v1alpha1.ExportAccess(export.Access),
+		Squash:         v1alpha1.ExportSquash(export.Squash),
+		AnonymousUID:   export.AnonymousUID,
+		AnonymousGID:   export.AnonymousGID,
+		AllowedClients: export.AllowedClients,
+	}
+	for _, v := range export.NFSVersions {
+		dst.Spec.Exports.NFSVersions = append(dst.Spec.Exports.NFSVersions, v1alpha1.NFSVersion(v))
+	}
+
+	dst.Status = v1alpha1.NfsStatus{
+		Capacity:           src.Status.Capacity,
+		AccessMode:         src.Status.AccessMode,
+		RequestedCapacity:  src.Status.RequestedCapacity,
+		Status:             src.Status.Status,
+		ObservedGeneration: src.Status.ObservedGeneration,
+		ProvisionerImage:   src.Status.Image,
+		ProvisionerImageID: src.Status.ImageID,
+		Conditions:         copyConditions(src.Status.Conditions),
+	}
+	// both versions have the same usage, auto expansion and extra resources fields
+	dst.Status.Usage = (*v1alpha1.UsageStatus)(src.Status.Usage.DeepCopy())
+	dst.Status.AutoExpansion = (*v1alpha1.AutoExpansionStatus)(src.Status.AutoExpansion.DeepCopy())
+	for _, ref := range src.Status.ExtraResources {
+		dst.Status.ExtraResources = append(dst.Status.ExtraResources, v1alpha1.ExtraResourceReference(ref))
+	}
+
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1alpha1) to this version. The
+// sizes that are not valid quantities are kept in the rawQuantitiesAnnotation,
+// a conversion error would make the Nfs, and every list with it, unreadable
+func (dst *Nfs) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.Nfs)
+
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	raw := map[string]string{}
+
+	dst.Spec.StorageClass = StorageClassSpec{
+		Name:                 src.Spec.StorageClass,
+		Provisioner:          src.Spec.ProvisionerAPI,
+		StorageClassSettings: convertStorageClassSettingsFrom(src.Spec.StorageClassTemplate),
+	}
+	for _, p := range src.Spec.StorageClassProfiles {
+		dst.Spec.StorageClass.Profiles = append(dst.Spec.StorageClass.Profiles, StorageClassProfile{
+			Name:                 p.Name,
+			StorageClassSettings: convertStorageClassSettingsFrom(p.StorageClassTemplateSpec),
+		})
+	}
+	dst.Spec.DeletionPolicy = DeletionPolicy(src.Spec.DeletionPolicy)
+	dst.Spec.ExtraResources = copyRawExtensions(src.Spec.ExtraResources)
+
+	dst.Spec.Backend = BackendSpec{
+		Type:         BackendType(src.Spec.BackingStorage.Type),
+		ClaimName:    src.Spec.BackingStorage.PvcName,
+		StorageClass: src.Spec.BackingStorage.StorageClass,
+		HostPath:     src.Spec.BackingStorage.HostPath,
+	}
+	if size := src.Spec.BackingStorage.StorageSize; len(size) != 0 {
+		if q, ok := parseQuantity(size, rawStorageSize, raw); ok {
+			dst.Spec.Backend.Size = &q
+		}
+	}
+	if ae := src.Spec.BackingStorage.AutoExpand.DeepCopy(); ae != nil {
+		step, _ := parseQuantity(ae.Step, rawStep, raw)
+		maxSize, _ := parseQuantity(ae.MaxSize, rawMaxSize, raw)
+		dst.Spec.Backend.AutoExpand = &AutoExpandSpec{
+			TriggerPercent: ae.TriggerPercent,
+			Step:           step,
+			MaxSize:        maxSize,
+			Cooldown:       ae.Cooldown,
+		}
+	}
+
+	dst.Spec.Image = src.Spec.Provisioner.Image
+	dst.Spec.ImagePullSecrets = copyLocalObjectReferences(src.Spec.Provisioner.ImagePullSecrets)
+	dst.Spec.Probes = ProbesSpec{
+		Liveness:  convertProbeFrom(src.Spec.Provisioner.Probes.Liveness),
+		Readiness: convertProbeFrom(src.Spec.Provisioner.Probes.Readiness),
+	}
+	usage := src.Spec.Usage.DeepCopy()
+	dst.Spec.Usage = UsageSpec{
+		Disabled:         usage.Disabled,
+		Interval:         usage.Interval,
+		WarningThreshold: usage.WarningThreshold,
+	}
+	pod := src.Spec.Provisioner.PodTemplate.DeepCopy()
+	dst.Spec.Resources = pod.Resources
+	dst.Spec.Pod = ProvisionerPodSpec{
+		Annotations:       pod.Annotations,
+		NodeSelector:      pod.NodeSelector,
+		Tolerations:       pod.Tolerations,
+		Affinity:          pod.Affinity,
+		PriorityClassName: pod.PriorityClassName,
+		Env:               pod.Env,
+	}
+
+	exports := src.Spec.Exports.DeepCopy()
+	dst.Spec.Export = ExportSpec{
+		Access:         ExportAccess(exports.Access),
+		Squash:         ExportSquash(exports.Squash),
+		AnonymousUID:   exports.AnonymousUID,
+		AnonymousGID:   exports.AnonymousGID,
+		AllowedClients: exports.AllowedClients,
+	}
+	for _, v := range exports.NFSVersions {
+		dst.Spec.Export.NFSVersions = append(dst.Spec.Export.NFSVersions, NFSVersion(v))
+	}
+
+	setRawQuantities(&dst.ObjectMeta, raw)
+
+	dst.Status = NfsStatus{
+		Capacity:           src.Status.Capacity,
+		AccessMode:         src.Status.AccessMode,
+		RequestedCapacity:  src.Status.RequestedCapacity,
+		Status:             src.Status.Status,
+		ObservedGeneration: src.Status.ObservedGeneration,
+		Image:              src.Status.ProvisionerImage,
+		ImageID:            src.Status.ProvisionerImageID,
+		Conditions:         copyConditions(src.Status.Conditions),
+	}
+	dst.Status.Usage = (*UsageStatus)(src.Status.Usage.DeepCopy())
+	dst.Status.AutoExpansion = (*AutoExpansionStatus)(src.Status.AutoExpansion.DeepCopy())
+	for _, ref := range src.Status.ExtraResources {
+		dst.Status.ExtraResources = append(dst.Status.ExtraResources, ExtraResourceReference(ref))
+	}
+
+	return nil
+}
+
+// parseQuantity returns the quantity of the v1alpha1 size, if it's not valid it
+// returns false and keeps the size in raw with the given path
+func parseQuantity(value, path string, raw map[string]string) (resource.Quantity, bool) {
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		raw[path] = value
+		return resource.Quantity{}, false
+	}
+	return q, true
+}
+
+// quantityString returns the quantity as a v1alpha1 size, or the raw size if
+// the quantity is not set because the size was not valid
+func quantityString(q resource.Quantity, raw string) string {
+	if q.IsZero() && len(raw) != 0 {
+		return raw
+	}
+	return q.String()
+}
+
+// setRawQuantities sets the rawQuantitiesAnnotation with the given sizes, or
+// removes it if there are none
+func setRawQuantities(meta *metav1.ObjectMeta, raw map[string]string) {
+	if len(raw) == 0 {
+		delete(meta.Annotations, rawQuantitiesAnnotation)
+		return
+	}
+	// a map of strings is always encoded
+	data, _ := json.Marshal(raw)
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[rawQuantitiesAnnotation] = string(data)
+}
+
+// rawQuantities returns the sizes kept in the rawQuantitiesAnnotation, an
+// annotation modified by the user that cannot be decoded is ignored
+func rawQuantities(annotations map[string]string) map[string]string {
+	raw := map[string]string{}
+	if data, ok := annotations[rawQuantitiesAnnotation]; ok {
+		if err := json.Unmarshal([]byte(data), &raw); err != nil {
+			return map[string]string{}
+		}
+	}
+	return raw
+}
+
+// convertStorageClassSettingsTo returns a copy of the StorageClass settings as
+// the v1alpha1 storageClassTemplate
+func convertStorageClassSettingsTo(in StorageClassSettings) v1alpha1.StorageClassTemplateSpec {
+	sc := in.DeepCopy()
+	return v1alpha1.StorageClassTemplateSpec{
+		Labels:               sc.Labels,
+		Annotations:          sc.Annotations,
+		Parameters:           sc.Parameters,
+		MountOptions:         sc.MountOptions,
+		ReclaimPolicy:        sc.ReclaimPolicy,
+		AllowVolumeExpansion: sc.AllowVolumeExpansion,
+		VolumeBindingMode:    sc.VolumeBindingMode,
+		Default:              sc.Default,
+	}
+}
+
+// convertStorageClassSettingsFrom returns a copy of the v1alpha1
+// storageClassTemplate as the StorageClass settings
+func convertStorageClassSettingsFrom(in v1alpha1.StorageClassTemplateSpec) StorageClassSettings {
+	sc := in.DeepCopy()
+	return StorageClassSettings{
+		Labels:               sc.Labels,
+		Annotations:          sc.Annotations,
+		Parameters:           sc.Parameters,
+		MountOptions:         sc.MountOptions,
+		ReclaimPolicy:        sc.ReclaimPolicy,
+		AllowVolumeExpansion: sc.AllowVolumeExpansion,
+		VolumeBindingMode:    sc.VolumeBindingMode,
+		Default:              sc.Default,
+	}
+}
+
+// convertProbeTo returns a copy of the probe settings in the v1alpha1 type
+func convertProbeTo(in ProbeSpec) v1alpha1.ProbeSpec {
+	p := in.DeepCopy()
+	return v1alpha1.ProbeSpec{
+		Disabled:            p.Disabled,
+		Type:                v1alpha1.ProbeType(p.Type),
+		InitialDelaySeconds: p.InitialDelaySeconds,
+		PeriodSeconds:       p.PeriodSeconds,
+		TimeoutSeconds:      p.TimeoutSeconds,
+		FailureThreshold:    p.FailureThreshold,
+	}
+}
+
+// convertProbeFrom returns a copy of the v1alpha1 probe settings in this type
+func convertProbeFrom(in v1alpha1.ProbeSpec) ProbeSpec {
+	p := in.DeepCopy()
+	return ProbeSpec{
+		Disabled:            p.Disabled,
+		Type:                ProbeType(p.Type),
+		InitialDelaySeconds: p.InitialDelaySeconds,
+		PeriodSeconds:       p.PeriodSeconds,
+		TimeoutSeconds:      p.TimeoutSeconds,
+		FailureThreshold:    p.FailureThreshold,
+	}
+}
+
+// copyConditions returns a deep copy of the conditions, both versions share the
+// same conditions type
+func copyConditions(in status.Conditions) status.Conditions {
+	if in == nil {
+		return nil
+	}
+	out := make(status.Conditions, len(in))
+	for i := range in {
+		in[i].DeepCopyInto(&out[i])
+	}
+	return out
+}
+
+// copyRawExtensions returns a deep copy of the manifests, both versions share
+// the same type
+func copyRawExtensions(in []runtime.RawExtension) []runtime.RawExtension {
+	if in == nil {
+		return nil
+	}
+	out := make([]runtime.RawExtension, len(in))
+	for i := range in {
+		in[i].DeepCopyInto(&out[i])
+	}
+	return out
+}
+
+// copyLocalObjectReferences returns a copy of the references, both versions
+// share the same type
+func copyLocalObjectReferences(in []corev1.LocalObjectReference) []corev1.LocalObjectReference {
+	if in == nil {
+		return nil
+	}
+	out := make([]corev1.LocalObjectReference, len(in))
+	copy(out, in)
+	return out
+}
@@ -0,0 +1,213 @@
+package v1alpha2
+
+import (
+	"testing"
+	"time"
+
+	"github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/diff"
+)
+
+// newHubNfs returns a v1alpha1 Nfs with every field set
+func newHubNfs() *v1alpha1.Nfs {
+	retain := corev1.PersistentVolumeReclaimRetain
+	waitForConsumer := storagev1.VolumeBindingWaitForFirstConsumer
+	allow := true
+	uid, gid := int64(65534), int64(65534)
+	period, threshold := int32(20), int32(85)
+	now := metav1.NewTime(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	return &v1alpha1.Nfs{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "nfs",
+			Namespace:   "test",
+			Labels:      map[string]string{"team": "storage"},
+			Annotations: map[string]string{"note": "converted"},
+		},
+		Spec: v1alpha1.NfsSpec{
+			StorageClass:   "shared",
+			ProvisionerAPI: "example.com/nfs",
+			StorageClassTemplate: v1alpha1.StorageClassTemplateSpec{
+				Labels:               map[string]string{"tier": "gold"},
+				Parameters:           map[string]string{"archiveOnDelete": "true"},
+				MountOptions:         []string{"vers=4.1"},
+				ReclaimPolicy:        &retain,
+				AllowVolumeExpansion: &allow,
+				VolumeBindingMode:    &waitForConsumer,
+				Default:              true,
+			},
+			StorageClassProfiles: []v1alpha1.StorageClassProfile{
+				{Name: "shared-v3", StorageClassTemplateSpec: v1alpha1.StorageClassTemplateSpec{MountOptions: []string{"vers=3"}}},
+			},
+			BackingStorage: v1alpha1.BackingStorageSpec{
+				Type:         v1alpha1.BackendPVC,
+				PvcName:      "data",
+				StorageClass: "block",
+				StorageSize:  "10Gi",
+				AutoExpand: &v1alpha1.AutoExpandSpec{
+					TriggerPercent: 80,
+					Step:           "5Gi",
+					MaxSize:        "50Gi",
+					Cooldown:       &metav1.Duration{Duration: time.Hour},
+				},
+			},
+			DeletionPolicy: v1alpha1.DeletionPolicyRetain,
+			Exports: v1alpha1.ExportsSpec{
+				Access:         v1alpha1.ExportReadOnly,
+				Squash:         v1alpha1.ExportSquashAll,
+				AnonymousUID:   &uid,
+				AnonymousGID:   &gid,
+				AllowedClients: []string{"10.0.0.0/8"},
+				NFSVersions:    []v1alpha1.NFSVersion{4},
+			},
+			Provisioner: v1alpha1.ProvisionerSpec{
+				Image:            "quay.io/kubernetes_incubator/nfs-provisioner:v2.3.0",
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+				PodTemplate: v1alpha1.PodTemplateSpec{
+					Annotations:       map[string]string{"backup": "false"},
+					NodeSelector:      map[string]string{"storage": "true"},
+					Tolerations:       []corev1.Toleration{{Key: "storage", Operator: corev1.TolerationOpExists}},
+					PriorityClassName: "storage",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+					},
+					Env: []corev1.EnvVar{{Name: "GANESHA_LOG", Value: "debug"}},
+				},
+				Probes: v1alpha1.ProbesSpec{
+					Liveness:  v1alpha1.ProbeSpec{Type: v1alpha1.ProbeTCP, PeriodSeconds: &period},
+					Readiness: v1alpha1.ProbeSpec{Disabled: true},
+				},
+			},
+			Usage: v1alpha1.UsageSpec{
+				Interval:         &metav1.Duration{Duration: 5 * time.Minute},
+				WarningThreshold: &threshold,
+			},
+			ExtraResources: []runtime.RawExtension{{Raw: []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"extra"}}`)}},
+		},
+		Status: v1alpha1.NfsStatus{
+			Capacity:           "10Gi",
+			AccessMode:         "ReadWriteOnce",
+			RequestedCapacity:  "15Gi",
+			Status:             v1alpha1.PhaseReady,
+			ObservedGeneration: 3,
+			ProvisionerImage:   "quay.io/kubernetes_incubator/nfs-provisioner:v2.3.0",
+			ProvisionerImageID: "quay.io/kubernetes_incubator/nfs-provisioner@sha256:0123",
+			Usage: &v1alpha1.UsageStatus{
+				Capacity:       resource.MustParse("10Gi"),
+				Used:           resource.MustParse("8Gi"),
+				Available:      resource.MustParse("2Gi"),
+				UsedPercent:    80,
+				LastUpdateTime: now,
+			},
+			AutoExpansion: &v1alpha1.AutoExpansionStatus{
+				Count:             1,
+				LastExpansionTime: now,
+				From:              resource.MustParse("10Gi"),
+				To:                resource.MustParse("15Gi"),
+			},
+			ExtraResources: []v1alpha1.ExtraResourceReference{
+				{APIVersion: "v1", Kind: "ConfigMap", Namespace: "test", Name: "settings"},
+			},
+			Conditions: status.Conditions{
+				{Type: v1alpha1.ConditionReady, Status: corev1.ConditionTrue, Reason: "Ready"},
+			},
+		},
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		modify     func(hub *v1alpha1.Nfs)
+		wantRaw    bool
+		wantSize   string
+		wantNoSize bool
+	}{
+		{
+			name:     "every field",
+			modify:   func(hub *v1alpha1.Nfs) {},
+			wantSize: "10Gi",
+		},
+		{
+			name:   "empty",
+			modify: func(hub *v1alpha1.Nfs) { *hub = v1alpha1.Nfs{} },
+			// no size, the claim is provided by the user
+			wantNoSize: true,
+		},
+		{
+			name: "invalid sizes",
+			modify: func(hub *v1alpha1.Nfs) {
+				hub.Spec.BackingStorage.StorageSize = "10GB"
+				hub.Spec.BackingStorage.AutoExpand.Step = "5 Gi"
+				hub.Spec.BackingStorage.AutoExpand.MaxSize = "a lot"
+			},
+			wantRaw:    true,
+			wantNoSize: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := newHubNfs()
+			tt.modify(hub)
+
+			spoke := &Nfs{}
+			if err := spoke.ConvertFrom(hub.DeepCopy()); err != nil {
+				t.Fatalf("ConvertFrom() error = %v", err)
+			}
+			if _, ok := spoke.Annotations[rawQuantitiesAnnotation]; ok != tt.wantRaw {
+				t.Errorf("ConvertFrom() annotation %s = %v, want %v", rawQuantitiesAnnotation, ok, tt.wantRaw)
+			}
+			if tt.wantNoSize != (spoke.Spec.Backend.Size == nil) {
+				t.Errorf("ConvertFrom() size = %v, want none %v", spoke.Spec.Backend.Size, tt.wantNoSize)
+			} else if !tt.wantNoSize && spoke.Spec.Backend.Size.String() != tt.wantSize {
+				t.Errorf("ConvertFrom() size = %s, want %s", spoke.Spec.Backend.Size.String(), tt.wantSize)
+			}
+
+			got := &v1alpha1.Nfs{}
+			if err := spoke.ConvertTo(got); err != nil {
+				t.Fatalf("ConvertTo() error = %v", err)
+			}
+			if !equality.Semantic.DeepEqual(hub, got) {
+				t.Errorf("round trip changed the Nfs:\n%s", diff.ObjectReflectDiff(hub, got))
+			}
+
+			again := &Nfs{}
+			if err := again.ConvertFrom(got); err != nil {
+				t.Fatalf("ConvertFrom() error = %v", err)
+			}
+			if !equality.Semantic.DeepEqual(spoke, again) {
+				t.Errorf("round trip changed the v1alpha2 Nfs:\n%s", diff.ObjectReflectDiff(spoke, again))
+			}
+		})
+	}
+}
+
+func TestConvertToFixedSize(t *testing.T) {
+	hub := newHubNfs()
+	hub.Spec.BackingStorage.StorageSize = "10GB"
+	spoke := &Nfs{}
+	if err := spoke.ConvertFrom(hub); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+
+	// the size fixed in this version replaces the raw size
+	size := resource.MustParse("20Gi")
+	spoke.Spec.Backend.Size = &size
+	got := &v1alpha1.Nfs{}
+	if err := spoke.ConvertTo(got); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	if got.Spec.BackingStorage.StorageSize != "20Gi" {
+		t.Errorf("ConvertTo() storageSize = %s, want 20Gi", got.Spec.BackingStorage.StorageSize)
+	}
+	if _, ok := got.Annotations[rawQuantitiesAnnotation]; ok {
+		t.Errorf("ConvertTo() kept the annotation %s", rawQuantitiesAnnotation)
+	}
+}
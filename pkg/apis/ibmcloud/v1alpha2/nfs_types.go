@@ -0,0 +1,514 @@
+package v1alpha2
+
+import (
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// BackendType is the type of storage exported by the NFS provisioner
+// +kubebuilder:validation:Enum=vpc-block;pvc;hostPath;emptyDir
+type BackendType string
+
+const (
+	// BackendVPCBlock is a claim of IBM Cloud VPC Block storage
+	BackendVPCBlock BackendType = "vpc-block"
+	// BackendPVC is a claim of any storage class
+	BackendPVC BackendType = "pvc"
+	// BackendHostPath is a directory on the node running the NFS provisioner
+	BackendHostPath BackendType = "hostPath"
+	// BackendEmptyDir is an ephemeral volume, the data is lost when the NFS
+	// provisioner Pod is deleted
+	BackendEmptyDir BackendType = "emptyDir"
+)
+
+// BackendSpec defines the storage exported by the NFS provisioner
+type BackendSpec struct {
+	// Type is the type of backend storage: vpc-block, pvc, hostPath or emptyDir
+	// +optional
+	// +kubebuilder:default=vpc-block
+	Type BackendType `json:"type,omitempty"`
+
+	// ClaimName is the name of the claim used by the vpc-block and pvc types, if
+	// not set it's the Nfs name with the suffix "-nfs-block"
+	// +optional
+	ClaimName string `json:"claimName,omitempty"`
+
+	// StorageClass is the storage class of the claim used by the vpc-block and
+	// pvc types
+	// +optional
+	StorageClass string `json:"storageClass,omitempty"`
+
+	// Size is the size of the claim, or the size limit of the emptyDir type.
+	// Without size the claim is not created, it's provided by the user
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// HostPath is the directory on the node used by the hostPath type, if not
+	// set it's /var/lib/nfs-operator/<namespace>/<name>
+	// +optional
+	HostPath string `json:"hostPath,omitempty"`
+
+	// AutoExpand grows the claim created by the operator before the exported
+	// volume fills up
+	// +optional
+	AutoExpand *AutoExpandSpec `json:"autoExpand,omitempty"`
+}
+
+// AutoExpandSpec is the policy to grow the backend claim when the used space of
+// the exported volume reaches a percentage. It requires the filesystem usage
+// and a storage class that allows volume expansion
+type AutoExpandSpec struct {
+	// TriggerPercent is the percentage of used space that grows the claim
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	TriggerPercent int32 `json:"triggerPercent"`
+
+	// Step is the size added to the claim on every expansion
+	Step resource.Quantity `json:"step"`
+
+	// MaxSize is the size the claim does not grow beyond
+	MaxSize resource.Quantity `json:"maxSize"`
+
+	// Cooldown is the minimum time between expansions, 15m if not set. The claim
+	// is not expanded either while a resize is in progress
+	// +optional
+	Cooldown *metav1.Duration `json:"cooldown,omitempty"`
+}
+
+// StorageClassSpec defines the StorageClass served by the NFS provisioner
+type StorageClassSpec struct {
+	// Name is the name of the StorageClass, if not set it's the Nfs namespace
+	// and name
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Provisioner is the name of the NFS provisioner, if not set it's unique
+	// for the Nfs namespace and name
+	// +optional
+	Provisioner string `json:"provisioner,omitempty"`
+
+	StorageClassSettings `json:",inline"`
+
+	// Profiles are other StorageClasses served by the NFS provisioner, the
+	// StorageClasses removed from the list are deleted
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Profiles []StorageClassProfile `json:"profiles,omitempty"`
+}
+
+// StorageClassProfile is other StorageClass served by the same NFS provisioner,
+// with its own settings
+type StorageClassProfile struct {
+	// Name is the name of the StorageClass
+	Name string `json:"name"`
+
+	StorageClassSettings `json:",inline"`
+}
+
+// StorageClassSettings are the settings of a StorageClass served by the NFS
+// provisioner
+type StorageClassSettings struct {
+	// Labels are added to the labels of the StorageClass
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are added to the annotations of the StorageClass
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Parameters are the parameters of the StorageClass for the NFS provisioner
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+
+	// MountOptions are the mount options of the volumes, if not set it's the
+	// NFS version 4.1, or 3 if the export serves only the NFS version 3
+	// +optional
+	MountOptions []string `json:"mountOptions,omitempty"`
+
+	// ReclaimPolicy is the reclaim policy of the volumes: Delete or Retain
+	// +optional
+	// +kubebuilder:validation:Enum=Delete;Retain
+	ReclaimPolicy *corev1.PersistentVolumeReclaimPolicy `json:"reclaimPolicy,omitempty"`
+
+	// AllowVolumeExpansion allows to expand the claims of the StorageClass
+	// +optional
+	AllowVolumeExpansion *bool `json:"allowVolumeExpansion,omitempty"`
+
+	// VolumeBindingMode is when the volumes are provisioned and bound:
+	// Immediate or WaitForFirstConsumer
+	// +optional
+	// +kubebuilder:validation:Enum=Immediate;WaitForFirstConsumer
+	VolumeBindingMode *storagev1.VolumeBindingMode `json:"volumeBindingMode,omitempty"`
+
+	// Default marks the StorageClass as the cluster default. It's refused if
+	// other StorageClass is the default
+	// +optional
+	Default bool `json:"default,omitempty"`
+}
+
+// ProbeType is how the NFS server is probed
+// +kubebuilder:validation:Enum=RPC;TCP
+type ProbeType string
+
+const (
+	// ProbeRPC calls the NFS service through rpcbind, it fails if rpcbind or
+	// the NFS server do not answer
+	ProbeRPC ProbeType = "RPC"
+	// ProbeTCP opens a connection to the NFS port, it does not detect a hung
+	// NFS server that still accepts connections
+	ProbeTCP ProbeType = "TCP"
+)
+
+// ProbeSpec has the settings of a probe of the NFS server. The settings not set
+// are the operator defaults
+type ProbeSpec struct {
+	// Disabled removes the probe from the NFS provisioner container
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Type is how the NFS server is probed: RPC (default) or TCP
+	// +optional
+	Type ProbeType `json:"type,omitempty"`
+
+	// InitialDelaySeconds is the time after the container starts before the
+	// first probe
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	InitialDelaySeconds *int32 `json:"initialDelaySeconds,omitempty"`
+
+	// PeriodSeconds is the time between probes
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	PeriodSeconds *int32 `json:"periodSeconds,omitempty"`
+
+	// TimeoutSeconds is the time after which the probe fails
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+
+	// FailureThreshold is the number of consecutive failures to consider the
+	// NFS server not ready, or to restart it
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	FailureThreshold *int32 `json:"failureThreshold,omitempty"`
+}
+
+// ProbesSpec has the probes of the NFS server
+type ProbesSpec struct {
+	// Liveness restarts the NFS provisioner container when it fails
+	// +optional
+	Liveness ProbeSpec `json:"liveness,omitempty"`
+
+	// Readiness removes the NFS provisioner Pod from the Service when it fails
+	// +optional
+	Readiness ProbeSpec `json:"readiness,omitempty"`
+}
+
+// ExportAccess is the access granted to the clients of the export
+// +kubebuilder:validation:Enum=ReadWrite;ReadOnly
+type ExportAccess string
+
+const (
+	// ExportReadWrite allows the clients to read and write
+	ExportReadWrite ExportAccess = "ReadWrite"
+	// ExportReadOnly allows the clients to read only
+	ExportReadOnly ExportAccess = "ReadOnly"
+)
+
+// ExportSquash is the user mapping applied to the clients of the export
+// +kubebuilder:validation:Enum=None;Root;All
+type ExportSquash string
+
+const (
+	// ExportSquashNone keeps the user of the clients
+	ExportSquashNone ExportSquash = "None"
+	// ExportSquashRoot maps the root user of the clients to the anonymous user
+	ExportSquashRoot ExportSquash = "Root"
+	// ExportSquashAll maps every user of the clients to the anonymous user
+	ExportSquashAll ExportSquash = "All"
+)
+
+// NFSVersion is a major version of the NFS protocol
+// +kubebuilder:validation:Enum=3;4
+type NFSVersion int32
+
+// ExportSpec defines how the backend storage is exported
+type ExportSpec struct {
+	// Access is the access granted to the clients: ReadWrite or ReadOnly
+	// +optional
+	// +kubebuilder:default=ReadWrite
+	Access ExportAccess `json:"access,omitempty"`
+
+	// Squash is the user mapping applied to the clients: None, Root or All
+	// +optional
+	// +kubebuilder:default=None
+	Squash ExportSquash `json:"squash,omitempty"`
+
+	// AnonymousUID is the user ID of the squashed users
+	// +optional
+	AnonymousUID *int64 `json:"anonymousUID,omitempty"`
+
+	// AnonymousGID is the group ID of the squashed users
+	// +optional
+	AnonymousGID *int64 `json:"anonymousGID,omitempty"`
+
+	// AllowedClients are the IP addresses or CIDRs of the clients allowed to
+	// mount the volumes, if not set every client is allowed
+	// +optional
+	AllowedClients []string `json:"allowedClients,omitempty"`
+
+	// NFSVersions are the versions of the NFS protocol served, if not set the
+	// versions 3 and 4 are served
+	// +optional
+	NFSVersions []NFSVersion `json:"nfsVersions,omitempty"`
+}
+
+// DeletionPolicy describes what happens to the backend storage when the Nfs is
+// deleted
+// +kubebuilder:validation:Enum=Retain;Delete
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyRetain keeps the backend storage when the Nfs is deleted
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyDelete removes the backend storage when the Nfs is deleted
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+)
+
+// ProvisionerPodSpec has the scheduling settings of the NFS provisioner Pod,
+// applied over the Deployment manifest template
+type ProvisionerPodSpec struct {
+	// Annotations are added to the annotations of the Pod
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// NodeSelector is added to the node selector of the Pod
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations are added to the tolerations of the Pod
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity replaces the scheduling constraints of the Pod
+	// +optional
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// PriorityClassName is the name of the PriorityClass of the Pod
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// Env are added to the environment variables of the NFS provisioner
+	// container. The variables set by the operator cannot be replaced
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+}
+
+// UsageSpec has the settings of the report of the filesystem usage of the
+// exported volume, collected from the kubelet volume stats of the NFS
+// provisioner Pod
+type UsageSpec struct {
+	// Disabled stops collecting the filesystem usage
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Interval is the time between collections of the filesystem usage, 1m if
+	// not set. It cannot be less than 10s
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// WarningThreshold is the percentage of used space or inodes that sets the
+	// StorageUsageHigh condition to True. If not set the condition is not
+	// reported
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	WarningThreshold *int32 `json:"warningThreshold,omitempty"`
+}
+
+// NfsSpec defines the desired state of Nfs
+type NfsSpec struct {
+	// Backend is the storage exported by the NFS provisioner
+	// +optional
+	Backend BackendSpec `json:"backend,omitempty"`
+
+	// StorageClass is the StorageClass served by the NFS provisioner
+	// +optional
+	StorageClass StorageClassSpec `json:"storageClass,omitempty"`
+
+	// Export defines how the backend storage is exported
+	// +optional
+	Export ExportSpec `json:"export,omitempty"`
+
+	// Resources are the compute resources of the NFS provisioner container
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Pod has the scheduling settings of the NFS provisioner Pod
+	// +optional
+	Pod ProvisionerPodSpec `json:"pod,omitempty"`
+
+	// Image is the NFS provisioner container image, as a tag or a digest. If not
+	// set the operator default is used
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// ImagePullSecrets are the Secrets used to pull the NFS provisioner image
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Probes are the liveness and readiness probes of the NFS server
+	// +optional
+	Probes ProbesSpec `json:"probes,omitempty"`
+
+	// Usage has the settings of the report of the filesystem usage of the
+	// exported volume
+	// +optional
+	Usage UsageSpec `json:"usage,omitempty"`
+
+	// DeletionPolicy defines if the backend storage is kept (Retain) or removed
+	// (Delete) when the Nfs is deleted
+	// +optional
+	// +kubebuilder:default=Delete
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// ExtraResources are manifests of other resources created with the NFS
+	// provisioner. They are Go templates rendered with the names of the Nfs and
+	// its resources, and they are deleted with the Nfs
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	ExtraResources []runtime.RawExtension `json:"extraResources,omitempty"`
+}
+
+// UsageStatus is the filesystem usage of the exported volume
+type UsageStatus struct {
+	// Capacity is the size of the filesystem
+	Capacity resource.Quantity `json:"capacity"`
+	// Used is the space used in the filesystem
+	Used resource.Quantity `json:"used"`
+	// Available is the space available in the filesystem
+	Available resource.Quantity `json:"available"`
+	// UsedPercent is the percentage of the capacity used
+	UsedPercent int32 `json:"usedPercent"`
+
+	// Inodes is the number of inodes of the filesystem
+	Inodes int64 `json:"inodes"`
+	// InodesUsed is the number of inodes used in the filesystem
+	InodesUsed int64 `json:"inodesUsed"`
+	// InodesFree is the number of inodes free in the filesystem
+	InodesFree int64 `json:"inodesFree"`
+
+	// LastUpdateTime is when the filesystem usage was collected
+	LastUpdateTime metav1.Time `json:"lastUpdateTime"`
+}
+
+// AutoExpansionStatus is the record of the automatic expansions of the backing
+// storage claim
+type AutoExpansionStatus struct {
+	// Count is the number of automatic expansions
+	Count int32 `json:"count"`
+	// LastExpansionTime is when the claim was expanded the last time
+	LastExpansionTime metav1.Time `json:"lastExpansionTime"`
+	// From is the size of the claim before the last expansion
+	From resource.Quantity `json:"from"`
+	// To is the size of the claim requested by the last expansion
+	To resource.Quantity `json:"to"`
+}
+
+// ExtraResourceReference identifies an extra resource applied by the operator
+type ExtraResourceReference struct {
+	// APIVersion is the API version of the resource
+	APIVersion string `json:"apiVersion"`
+	// Kind is the kind of the resource
+	Kind string `json:"kind"`
+	// Namespace is the namespace of the resource, empty if it's cluster scoped
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Name is the name of the resource
+	Name string `json:"name"`
+}
+
+// NfsStatus defines the observed state of Nfs
+type NfsStatus struct {
+	Capacity   string `json:"capacity,omitempty"`
+	AccessMode string `json:"accessMode,omitempty"`
+
+	// RequestedCapacity is the size requested on the backing storage claim while
+	// it's resized, the Capacity is its current size
+	// +optional
+	RequestedCapacity string `json:"requestedCapacity,omitempty"`
+
+	// Status is the phase of the Nfs: Pending, Ready or Failed
+	// +optional
+	Status string `json:"status,omitempty"`
+
+	// ObservedGeneration is the most recent generation observed by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Image is the image of the NFS provisioner Pod running once the latest
+	// Deployment rollout is complete
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// ImageID is the ID, with the digest, of the image pulled by the NFS
+	// provisioner Pod
+	// +optional
+	ImageID string `json:"imageID,omitempty"`
+
+	// Usage is the latest filesystem usage collected from the exported volume
+	// +optional
+	Usage *UsageStatus `json:"usage,omitempty"`
+
+	// AutoExpansion is the record of the automatic expansions of the backend
+	// claim
+	// +optional
+	AutoExpansion *AutoExpansionStatus `json:"autoExpansion,omitempty"`
+
+	// ExtraResources are the extra resources applied by the operator, the ones
+	// removed from the spec are deleted
+	// +optional
+	ExtraResources []ExtraResourceReference `json:"extraResources,omitempty"`
+
+	// Conditions is the list of the latest available observations of the Nfs
+	// +optional
+	Conditions status.Conditions `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Nfs is the Schema for the nfs API
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=nfs,scope=Namespaced
+// +kubebuilder:printcolumn:JSONPath=".status.capacity",name=Capacity,type=string
+// +kubebuilder:printcolumn:JSONPath=".spec.storageClass.name",name=StorageClass,type=string
+// +kubebuilder:printcolumn:JSONPath=".status.status",name=Status,type=string
+// +kubebuilder:printcolumn:JSONPath=".status.conditions[?(@.type==\"Ready\")].status",name=Ready,type=string
+type Nfs struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NfsSpec   `json:"spec,omitempty"`
+	Status NfsStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NfsList contains a list of Nfs
+type NfsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Nfs `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Nfs{}, &NfsList{})
+}
@@ -0,0 +1,36 @@
+package v1alpha2
+
+import (
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-ibmcloud-ibm-com-v1alpha2-nfs,mutating=true,failurePolicy=fail,groups=ibmcloud.ibm.com,resources=nfs,versions=v1alpha2,name=mnfs.v1alpha2.ibmcloud.ibm.com
+
+var _ webhook.Defaulter = &Nfs{}
+
+// SetupWebhookWithManager registers the defaulting webhook of the Nfs in the
+// webhook server of the manager. The validation is done by the v1alpha1
+// webhook, the API server sends it this version converted to v1alpha1
+func (r *Nfs) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// Default implements webhook.Defaulter, it sets the defaults of the fields
+// that are not computed from the Nfs name by the controller
+func (r *Nfs) Default() {
+	if len(r.Spec.Backend.Type) == 0 {
+		r.Spec.Backend.Type = BackendVPCBlock
+	}
+	if len(r.Spec.Export.Access) == 0 {
+		r.Spec.Export.Access = ExportReadWrite
+	}
+	if len(r.Spec.Export.Squash) == 0 {
+		r.Spec.Export.Squash = ExportSquashNone
+	}
+	if len(r.Spec.DeletionPolicy) == 0 {
+		r.Spec.DeletionPolicy = DeletionPolicyDelete
+	}
+}
@@ -0,0 +1,52 @@
+package nfs
+
+import (
+	"context"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// finalizerName is the finalizer set on every Nfs to clean up the resources
+// that cannot be garbage collected, like the cluster scoped resources
+const finalizerName = "nfs.ibmcloud.ibm.com/finalizer"
+
+// addFinalizer sets the finalizer on the Nfs instance if it's not there
+func (r *ReconcileNfs) addFinalizer(instance *ibmcloudv1alpha1.Nfs) error {
+	if hasFinalizer(instance) {
+		return nil
+	}
+	controllerutil.AddFinalizer(instance, finalizerName)
+	return r.client.Update(context.TODO(), instance)
+}
+
+// finalize cleans up the given resources and removes the finalizer from the
+// Nfs instance so it can be deleted
+func (r *ReconcileNfs) finalize(instance *ibmcloudv1alpha1.Nfs, owned []resources.Reconcilable) error {
+	if !hasFinalizer(instance) {
+		return nil
+	}
+
+	for _, res := range owned {
+		if f, ok := res.(resources.Finalizable); ok {
+			if err := f.Finalize(); err != nil {
+				r.recorder.Event(instance, corev1.EventTypeWarning, resources.ReasonFailed, err.Error())
+				return err
+			}
+		}
+	}
+
+	controllerutil.RemoveFinalizer(instance, finalizerName)
+	return r.client.Update(context.TODO(), instance)
+}
+
+func hasFinalizer(instance *ibmcloudv1alpha1.Nfs) bool {
+	for _, f := range instance.GetFinalizers() {
+		if f == finalizerName {
+			return true
+		}
+	}
+	return false
+}
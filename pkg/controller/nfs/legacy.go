@@ -0,0 +1,58 @@
+package nfs
+
+import (
+	"context"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// markLegacyNames sets the LegacyNamesAnnotation on a Nfs created before the
+// names of its resources were taken from the spec and the instance, it controls
+// the Deployment or the claim with the legacy names. The Nfs without the
+// finalizer is checked only once, on its first reconcile after the upgrade, a
+// new Nfs never controls them
+func (r *ReconcileNfs) markLegacyNames(instance *ibmcloudv1alpha1.Nfs) error {
+	if hasFinalizer(instance) || resources.HasLegacyNames(instance) {
+		return nil
+	}
+
+	legacy, err := r.controlsLegacyResources(instance)
+	if !legacy || err != nil {
+		return err
+	}
+
+	log.Info("Keeping the legacy names of the Nfs resources", "Request.Namespace", instance.Namespace, "Request.Name", instance.Name)
+	annotations := instance.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[resources.LegacyNamesAnnotation] = "true"
+	instance.SetAnnotations(annotations)
+	return r.client.Update(context.TODO(), instance)
+}
+
+// controlsLegacyResources returns true if the Nfs is the controller of the
+// Deployment or the claim with the legacy names
+func (r *ReconcileNfs) controlsLegacyResources(instance *ibmcloudv1alpha1.Nfs) (bool, error) {
+	legacy := map[string]runtime.Object{
+		resources.LegacyAppName:   &appsv1.Deployment{},
+		resources.LegacyClaimName: &corev1.PersistentVolumeClaim{},
+	}
+	for name, obj := range legacy {
+		err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: name}, obj)
+		exists, err := resources.Exists(err)
+		if err != nil {
+			return false, err
+		}
+		if exists && metav1.IsControlledBy(obj.(metav1.Object), instance) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,101 @@
+package nfs
+
+import (
+	"context"
+	"testing"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMarkLegacyNames(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := ibmcloudv1alpha1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	newNfs := func(finalizers ...string) *ibmcloudv1alpha1.Nfs {
+		return &ibmcloudv1alpha1.Nfs{
+			TypeMeta:   metav1.TypeMeta{APIVersion: ibmcloudv1alpha1.SchemeGroupVersion.String(), Kind: "Nfs"},
+			ObjectMeta: metav1.ObjectMeta{Name: "nfs", Namespace: "test", UID: "nfs-uid", Finalizers: finalizers},
+		}
+	}
+	controlledBy := func(owner *ibmcloudv1alpha1.Nfs) []metav1.OwnerReference {
+		return []metav1.OwnerReference{*metav1.NewControllerRef(owner, ibmcloudv1alpha1.SchemeGroupVersion.WithKind("Nfs"))}
+	}
+	legacyDeployment := func(refs []metav1.OwnerReference) runtime.Object {
+		return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: resources.LegacyAppName, Namespace: "test", OwnerReferences: refs}}
+	}
+	legacyClaim := func(refs []metav1.OwnerReference) runtime.Object {
+		return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: resources.LegacyClaimName, Namespace: "test", OwnerReferences: refs}}
+	}
+
+	tests := []struct {
+		name     string
+		instance *ibmcloudv1alpha1.Nfs
+		objects  func(owner *ibmcloudv1alpha1.Nfs) []runtime.Object
+		want     bool
+	}{
+		{
+			name:     "new Nfs",
+			instance: newNfs(),
+			objects:  func(*ibmcloudv1alpha1.Nfs) []runtime.Object { return nil },
+		},
+		{
+			name:     "controls the legacy Deployment",
+			instance: newNfs(),
+			objects: func(owner *ibmcloudv1alpha1.Nfs) []runtime.Object {
+				return []runtime.Object{legacyDeployment(controlledBy(owner))}
+			},
+			want: true,
+		},
+		{
+			name:     "controls the legacy claim",
+			instance: newNfs(),
+			objects: func(owner *ibmcloudv1alpha1.Nfs) []runtime.Object {
+				return []runtime.Object{legacyClaim(controlledBy(owner))}
+			},
+			want: true,
+		},
+		{
+			name:     "legacy names of other owner",
+			instance: newNfs(),
+			objects: func(*ibmcloudv1alpha1.Nfs) []runtime.Object {
+				return []runtime.Object{legacyDeployment(nil), legacyClaim(nil)}
+			},
+		},
+		{
+			name:     "already reconciled by this version",
+			instance: newNfs(finalizerName),
+			objects: func(owner *ibmcloudv1alpha1.Nfs) []runtime.Object {
+				return []runtime.Object{legacyDeployment(controlledBy(owner))}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := append(tt.objects(tt.instance), tt.instance.DeepCopy())
+			r := &ReconcileNfs{client: fake.NewFakeClientWithScheme(scheme, objs...), scheme: scheme}
+			if err := r.markLegacyNames(tt.instance); err != nil {
+				t.Fatalf("markLegacyNames() error = %v", err)
+			}
+			stored := &ibmcloudv1alpha1.Nfs{}
+			if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: "test", Name: "nfs"}, stored); err != nil {
+				t.Fatal(err)
+			}
+			if got := resources.HasLegacyNames(stored); got != tt.want {
+				t.Errorf("HasLegacyNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
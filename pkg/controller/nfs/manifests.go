@@ -0,0 +1,54 @@
+package nfs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/johandry/nfs-operator/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ManifestsConfigMap is the ConfigMap, as <namespace>/<name>, with the manifests
+// templates that override the templates embedded in the operator. It's set with
+// the operator flag --manifests-configmap, if empty the embedded templates are
+// used
+var ManifestsConfigMap string
+
+// manifestsConfigMapKey returns the namespace and name of the ManifestsConfigMap
+func manifestsConfigMapKey() (types.NamespacedName, error) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(ManifestsConfigMap)
+	if err != nil {
+		return types.NamespacedName{}, err
+	}
+	if len(namespace) == 0 || len(name) == 0 {
+		return types.NamespacedName{}, fmt.Errorf("invalid manifests ConfigMap %q, it has to be <namespace>/<name>", ManifestsConfigMap)
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}, nil
+}
+
+// manifests returns the manifests templates in the ManifestsConfigMap, every key
+// is the name of a template. The ConfigMap is read from the API server on every
+// reconcile, if it does not exists the embedded templates are used
+func (r *ReconcileNfs) manifests() (resources.Manifests, error) {
+	if len(ManifestsConfigMap) == 0 {
+		return nil, nil
+	}
+	key, err := manifestsConfigMapKey()
+	if err != nil {
+		return nil, err
+	}
+
+	cm := &corev1.ConfigMap{}
+	err = r.reader.Get(context.TODO(), key, cm)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return resources.Manifests(cm.Data), nil
+}
@@ -0,0 +1,155 @@
+package nfs
+
+import (
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	nfsReady = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nfs_operator_nfs_ready",
+		Help: "Whether the Nfs is ready (1) or not (0)",
+	}, []string{"namespace", "name"})
+
+	nfsBackingStorageRequested = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nfs_operator_nfs_backing_storage_requested_bytes",
+		Help: "Size in bytes of the backing storage requested by the Nfs",
+	}, []string{"namespace", "name"})
+
+	nfsBackingStorageBound = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nfs_operator_nfs_backing_storage_bound_bytes",
+		Help: "Size in bytes of the backing storage bound to the Nfs",
+	}, []string{"namespace", "name"})
+
+	nfsProvisionedVolumes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nfs_operator_nfs_provisioned_volumes",
+		Help: "Number of PersistentVolumes provisioned from the StorageClasses of the Nfs",
+	}, []string{"namespace", "name"})
+
+	nfsExportCapacity = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nfs_operator_nfs_export_capacity_bytes",
+		Help: "Size in bytes of the filesystem of the volume exported by the Nfs",
+	}, []string{"namespace", "name"})
+
+	nfsExportUsed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nfs_operator_nfs_export_used_bytes",
+		Help: "Space in bytes used in the filesystem of the volume exported by the Nfs",
+	}, []string{"namespace", "name"})
+
+	nfsExportAvailable = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nfs_operator_nfs_export_available_bytes",
+		Help: "Space in bytes available in the filesystem of the volume exported by the Nfs",
+	}, []string{"namespace", "name"})
+
+	nfsExportInodes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nfs_operator_nfs_export_inodes",
+		Help: "Number of inodes of the filesystem of the volume exported by the Nfs",
+	}, []string{"namespace", "name"})
+
+	nfsExportInodesUsed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nfs_operator_nfs_export_inodes_used",
+		Help: "Number of inodes used in the filesystem of the volume exported by the Nfs",
+	}, []string{"namespace", "name"})
+
+	nfsExportInodesFree = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nfs_operator_nfs_export_inodes_free",
+		Help: "Number of inodes free in the filesystem of the volume exported by the Nfs",
+	}, []string{"namespace", "name"})
+
+	// usageGauges are the gauges of the filesystem usage, set only if it's known
+	usageGauges = []*prometheus.GaugeVec{nfsExportCapacity, nfsExportUsed, nfsExportAvailable, nfsExportInodes, nfsExportInodesUsed, nfsExportInodesFree}
+)
+
+func init() {
+	// served by the controller-runtime metrics endpoint of the manager
+	metrics.Registry.MustRegister(nfsReady, nfsBackingStorageRequested, nfsBackingStorageBound, nfsProvisionedVolumes)
+	for _, gauge := range usageGauges {
+		metrics.Registry.MustRegister(gauge)
+	}
+}
+
+// updateMetrics sets the gauges of the Nfs from the given status and the
+// observed resources. The sizes unknown, like the size of a claim provided by
+// the user or not bound yet, are not exposed. If the resources were not
+// observed, like when the spec is invalid, the bound size is kept
+func (r *ReconcileNfs) updateMetrics(instance *ibmcloudv1alpha1.Nfs, st *ibmcloudv1alpha1.NfsStatus, observables []resources.Observable) {
+	labels := prometheus.Labels{"namespace": instance.Namespace, "name": instance.Name}
+
+	ready := 0.0
+	if st.Conditions.IsTrueFor(ibmcloudv1alpha1.ConditionReady) {
+		ready = 1
+	}
+	nfsReady.With(labels).Set(ready)
+
+	requested, err := resource.ParseQuantity(instance.Spec.BackingStorage.StorageSize)
+	setBytes(nfsBackingStorageRequested, labels, requested, err == nil)
+	if len(observables) != 0 {
+		bound, ok := boundCapacity(observables, st)
+		setBytes(nfsBackingStorageBound, labels, bound, ok)
+	}
+	setUsage(labels, st.Usage)
+
+	volumes, err := resources.ProvisionedVolumes(r.client, instance)
+	if err != nil {
+		log.Error(err, "Failed to count the provisioned volumes", "Request.Namespace", instance.Namespace, "Request.Name", instance.Name)
+		return
+	}
+	nfsProvisionedVolumes.With(labels).Set(float64(len(volumes)))
+}
+
+// boundCapacity returns the capacity of the backing storage reported by the
+// observed resources. Like the status capacity, if the backing storage does not
+// report it, it's the capacity of the exported filesystem
+func boundCapacity(observables []resources.Observable, st *ibmcloudv1alpha1.NfsStatus) (resource.Quantity, bool) {
+	for _, o := range observables {
+		if sized, ok := o.(resources.Sized); ok {
+			if capacity, ok := sized.Capacity(); ok {
+				return capacity, true
+			}
+		}
+	}
+	if st.Usage != nil {
+		return st.Usage.Capacity, true
+	}
+	return resource.Quantity{}, false
+}
+
+// setBytes sets the gauge to the quantity in bytes, or deletes it if the
+// quantity is not known
+func setBytes(gauge *prometheus.GaugeVec, labels prometheus.Labels, q resource.Quantity, known bool) {
+	if !known {
+		gauge.Delete(labels)
+		return
+	}
+	gauge.With(labels).Set(float64(q.Value()))
+}
+
+// setUsage sets the gauges of the filesystem usage, or deletes them if the
+// usage is not known
+func setUsage(labels prometheus.Labels, usage *ibmcloudv1alpha1.UsageStatus) {
+	if usage == nil {
+		for _, gauge := range usageGauges {
+			gauge.Delete(labels)
+		}
+		return
+	}
+	nfsExportCapacity.With(labels).Set(float64(usage.Capacity.Value()))
+	nfsExportUsed.With(labels).Set(float64(usage.Used.Value()))
+	nfsExportAvailable.With(labels).Set(float64(usage.Available.Value()))
+	nfsExportInodes.With(labels).Set(float64(usage.Inodes))
+	nfsExportInodesUsed.With(labels).Set(float64(usage.InodesUsed))
+	nfsExportInodesFree.With(labels).Set(float64(usage.InodesFree))
+}
+
+// deleteMetrics deletes the gauges of the deleted Nfs
+func deleteMetrics(namespace, name string) {
+	labels := prometheus.Labels{"namespace": namespace, "name": name}
+	nfsReady.Delete(labels)
+	nfsBackingStorageRequested.Delete(labels)
+	nfsBackingStorageBound.Delete(labels)
+	nfsProvisionedVolumes.Delete(labels)
+	setUsage(labels, nil)
+}
@@ -0,0 +1,95 @@
+package nfs
+
+import (
+	"testing"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// sizedStorage is an observed backing storage with the given capacity
+type sizedStorage struct {
+	capacity string
+}
+
+func (s sizedStorage) Observe(st *ibmcloudv1alpha1.NfsStatus) error { return nil }
+
+func (s sizedStorage) Capacity() (resource.Quantity, bool) {
+	if len(s.capacity) == 0 {
+		return resource.Quantity{}, false
+	}
+	return resource.MustParse(s.capacity), true
+}
+
+func TestUpdateMetricsBound(t *testing.T) {
+	tests := []struct {
+		name        string
+		observables []resources.Observable
+		status      ibmcloudv1alpha1.NfsStatus
+		wantBound   string
+	}{
+		{
+			name:        "bound claim",
+			observables: []resources.Observable{sizedStorage{"10Gi"}},
+			status:      ibmcloudv1alpha1.NfsStatus{Capacity: "10Gi"},
+			wantBound:   "10Gi",
+		},
+		{
+			name:        "resizing claim",
+			observables: []resources.Observable{sizedStorage{"10Gi"}},
+			status:      ibmcloudv1alpha1.NfsStatus{Capacity: "10Gi", RequestedCapacity: "15Gi"},
+			wantBound:   "10Gi",
+		},
+		{
+			name:        "claim not bound",
+			observables: []resources.Observable{sizedStorage{}},
+		},
+		{
+			name:        "filesystem of a storage without size",
+			observables: []resources.Observable{sizedStorage{}},
+			status: ibmcloudv1alpha1.NfsStatus{
+				Usage: &ibmcloudv1alpha1.UsageStatus{Capacity: resource.MustParse("8Gi")},
+			},
+			wantBound: "8Gi",
+		},
+		{
+			name:      "not observed keeps the bound size",
+			status:    ibmcloudv1alpha1.NfsStatus{Capacity: "invalid"},
+			wantBound: "1Gi",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := clientgoscheme.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			r := &ReconcileNfs{client: fake.NewFakeClientWithScheme(scheme), scheme: scheme}
+			instance := &ibmcloudv1alpha1.Nfs{ObjectMeta: metav1.ObjectMeta{Name: "nfs", Namespace: "test"}}
+			labels := prometheus.Labels{"namespace": "test", "name": "nfs"}
+			defer deleteMetrics("test", "nfs")
+			nfsBackingStorageBound.With(labels).Set(1 << 30)
+
+			r.updateMetrics(instance, &tt.status, tt.observables)
+
+			count := testutil.CollectAndCount(nfsBackingStorageBound)
+			if len(tt.wantBound) == 0 {
+				if count != 0 {
+					t.Errorf("updateMetrics() bound gauge is set, want it deleted")
+				}
+				return
+			}
+			want := resource.MustParse(tt.wantBound)
+			if got := testutil.ToFloat64(nfsBackingStorageBound.With(labels)); count != 1 || got != float64(want.Value()) {
+				t.Errorf("updateMetrics() bound = %v, want %v", got, want.Value())
+			}
+		})
+	}
+}
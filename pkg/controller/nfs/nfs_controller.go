@@ -2,33 +2,67 @@ package nfs
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
-	vpcblockbackend "github.com/johandry/nfs-operator/pkg/resources/backend/vpc-block"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	"github.com/johandry/nfs-operator/pkg/resources/backend"
+	"github.com/johandry/nfs-operator/pkg/resources/extra"
 	nfsprovisioner "github.com/johandry/nfs-operator/pkg/resources/provisioner/nfs"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 var log = logf.Log.WithName("controller_nfs")
 
+// requeueAfter is the time to wait to reconcile again a Nfs that is not ready
+const requeueAfter = 10 * time.Second
+
 // Add creates a new Nfs Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
-	return add(mgr, newReconciler(mgr))
+	if len(ManifestsConfigMap) != 0 {
+		if _, err := manifestsConfigMapKey(); err != nil {
+			return err
+		}
+	}
+	if msgs := ibmcloudv1alpha1.IsImageReference(nfsprovisioner.DefaultImage); len(msgs) != 0 {
+		return fmt.Errorf("invalid provisioner image %q. %s", nfsprovisioner.DefaultImage, strings.Join(msgs, ", "))
+	}
+	// the kubelet stats are requested through the API server proxy, it's not
+	// supported by the controller-runtime client
+	kubeClient, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	return add(mgr, newReconciler(mgr, kubeClient))
 }
 
 // newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileNfs{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+func newReconciler(mgr manager.Manager, kubeClient kubernetes.Interface) reconcile.Reconciler {
+	return &ReconcileNfs{
+		client:     mgr.GetClient(),
+		reader:     mgr.GetAPIReader(),
+		scheme:     mgr.GetScheme(),
+		mapper:     mgr.GetRESTMapper(),
+		recorder:   resources.NewEventRecorder(mgr.GetEventRecorderFor(resources.FieldManager)),
+		kubeClient: kubeClient,
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -45,17 +79,50 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
-	// TODO(user): Modify this to be the types you create that are owned by the primary resource
-	// Watch for changes to secondary resource Pods and requeue the owner Nfs
-	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestForOwner{
-		IsController: true,
-		OwnerType:    &ibmcloudv1alpha1.Nfs{},
-	})
+	// Watch for changes to every type of secondary resource and requeue the owner Nfs.
+	// The types of the extra resources are watched when they are reconciled
+	owned, err := ownedResources(mgr)
+	if err != nil {
+		return err
+	}
+	watcher := resources.NewWatcher(c, mgr.GetScheme())
+	err = watcher.Watch(owned)
 	if err != nil {
 		return err
 	}
+	if rn, ok := r.(*ReconcileNfs); ok {
+		rn.watcher = watcher
+	}
+
+	// The volumes provisioned for every Nfs are counted on each reconcile, and
+	// the NFS provisioner is restarted to set the export options on the new
+	// volumes, so a new or deleted volume requeues the Nfs of its StorageClass
+	if err := resources.IndexPersistentVolumes(mgr.GetFieldIndexer()); err != nil {
+		return err
+	}
+	return c.Watch(&source.Kind{Type: &corev1.PersistentVolume{}}, resources.EnqueueRequestForStorageClassOwner(mgr.GetClient()), predicate.Funcs{
+		UpdateFunc: func(event.UpdateEvent) bool { return false },
+	})
+}
 
-	return nil
+// ownedResources returns the resources of every type of backing storage and of
+// the NFS provisioner, created for an empty Nfs with the embedded manifests
+// templates and without recorder, to know the types to watch
+func ownedResources(mgr manager.Manager) ([]resources.Reconcilable, error) {
+	empty := &ibmcloudv1alpha1.Nfs{}
+	owned := []resources.Reconcilable{}
+	for _, factory := range backend.Factories {
+		storage, err := factory(empty, nil, mgr.GetClient(), mgr.GetScheme(), nil, log)
+		if err != nil {
+			return nil, err
+		}
+		owned = append(owned, storage.Resources()...)
+	}
+	provisioner, err := nfsprovisioner.New(empty, corev1.VolumeSource{}, nil, mgr.GetClient(), mgr.GetScheme(), nil, log)
+	if err != nil {
+		return nil, err
+	}
+	return append(owned, provisioner.Resources()...), nil
 }
 
 // blank assignment to verify that ReconcileNfs implements reconcile.Reconciler
@@ -66,7 +133,15 @@ type ReconcileNfs struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
-	scheme *runtime.Scheme
+	// reader reads from the API server, for the objects that are not watched
+	reader  client.Reader
+	scheme  *runtime.Scheme
+	mapper  meta.RESTMapper
+	watcher *resources.Watcher
+	// recorder emits the Events on the Nfs, similar Events are rate limited
+	recorder record.EventRecorder
+	// kubeClient requests the kubelet stats of the NFS provisioner Pod
+	kubeClient kubernetes.Interface
 }
 
 // Reconcile reads that state of the cluster for a Nfs object and makes changes based on the state read
@@ -84,33 +159,155 @@ func (r *ReconcileNfs) Reconcile(request reconcile.Request) (reconcile.Result, e
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
-			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+			// Owned objects are automatically garbage collected, the rest are cleaned up by the finalizer.
 			// Return and don't requeue
+			deleteMetrics(request.Namespace, request.Name)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
 
-	result, err := vpcblockbackend.New(instance, r.client, r.scheme, log).Reconcile()
+	// The names of the resources depend on it, it's set before they are created
+	if instance.GetDeletionTimestamp() == nil {
+		if err := r.markLegacyNames(instance); err != nil {
+			reqLogger.Error(err, "Failed to verify the legacy names of the resources")
+			return reconcile.Result{}, err
+		}
+	}
+
+	manifests, err := r.manifests()
 	if err != nil {
-		return result, err
+		reqLogger.Error(err, "Failed to load the manifests templates")
+		return reconcile.Result{}, err
+	}
+	storage, provisioner, templateErr := r.newResources(instance, manifests)
+	if templateErr != nil && instance.GetDeletionTimestamp() != nil {
+		// The names of the resources to finalize do not depend on the templates
+		reqLogger.Info("Finalizing with the embedded manifests templates", "error", templateErr.Error())
+		storage, provisioner, templateErr = r.newResources(instance, nil)
+	}
+	if templateErr != nil && !resources.IsTemplateError(templateErr) {
+		reqLogger.Error(templateErr, "Failed to create the resources")
+		return reconcile.Result{}, templateErr
+	}
+	owned := []resources.Reconcilable{}
+	if templateErr == nil {
+		owned = append(storage.Resources(), provisioner.Resources()...)
+	}
+	extras, extrasErr := extra.New(instance, resources.NewTemplateData(instance), r.mapper, r.client, r.scheme, r.recorder, log)
+	if extrasErr == nil {
+		owned = append(owned, extras.Resources()...)
+	}
+
+	// The Nfs is being deleted, clean up what the garbage collector cannot
+	if instance.GetDeletionTimestamp() != nil {
+		if extrasErr != nil {
+			reqLogger.Info("The extra resources cannot be finalized", "error", extrasErr.Error())
+		} else if err := extras.Prune(); err != nil {
+			reqLogger.Error(err, "Failed to delete the extra resources removed from the spec")
+			return reconcile.Result{}, err
+		}
+		reqLogger.Info("Finalizing Nfs")
+		return reconcile.Result{}, r.finalize(instance, owned)
+	}
+
+	// The webhook rejects an invalid spec, without it the error is reported on
+	// the status and it's not reconciled until the spec is fixed. A new Nfs is
+	// validated like the webhook does on create, with its name
+	validate := instance.Validate
+	if !hasFinalizer(instance) && !resources.HasLegacyNames(instance) {
+		validate = instance.ValidateCreate
+	}
+	if err := validate(); err != nil {
+		reqLogger.Info("Skip reconcile: Nfs spec is invalid", "error", err.Error())
+		r.recorder.Event(instance, corev1.EventTypeWarning, resources.ReasonInvalidSpec, err.Error())
+		return reconcile.Result{}, r.updateStatus(instance, nil, err)
+	}
+
+	// An invalid template has to be fixed by the cluster admin, it's reported on
+	// the status and checked again later as the ConfigMap is not watched
+	if templateErr != nil {
+		reqLogger.Info("Skip reconcile: Manifest template is invalid", "error", templateErr.Error())
+		r.recorder.Event(instance, corev1.EventTypeWarning, resources.ReasonInvalidTemplate, templateErr.Error())
+		return reconcile.Result{RequeueAfter: requeueAfter}, r.updateStatus(instance, nil, templateErr)
+	}
+
+	// A manifest that cannot be rendered is like an invalid spec, an unknown kind
+	// may be installed later so it's retried
+	if extrasErr != nil {
+		if errors.IsInvalid(extrasErr) {
+			reqLogger.Info("Skip reconcile: Nfs extra resources are invalid", "error", extrasErr.Error())
+			r.recorder.Event(instance, corev1.EventTypeWarning, resources.ReasonInvalidSpec, extrasErr.Error())
+		} else {
+			reqLogger.Error(extrasErr, "Failed to create the extra resources")
+			r.recorder.Event(instance, corev1.EventTypeWarning, resources.ReasonFailed, extrasErr.Error())
+		}
+		if statusErr := r.updateStatus(instance, nil, extrasErr); statusErr != nil || errors.IsInvalid(extrasErr) {
+			return reconcile.Result{}, statusErr
+		}
+		return reconcile.Result{}, extrasErr
+	}
+	if err := r.watcher.Watch(extras.Resources()); err != nil {
+		reqLogger.Error(err, "Failed to watch the extra resources")
+		return reconcile.Result{}, err
+	}
+
+	if err := r.addFinalizer(instance); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	result, err := storage.Reconcile()
+	if err == nil {
+		result, err = provisioner.Reconcile()
+	}
+	if err == nil {
+		result, err = extras.Reconcile()
+	}
+	if err == nil {
+		err = extras.Prune()
+	}
+	switch {
+	case resources.IsConflict(err):
+		r.recorder.Event(instance, corev1.EventTypeWarning, resources.ReasonConflict, err.Error())
+	case err != nil:
+		r.recorder.Event(instance, corev1.EventTypeWarning, resources.ReasonFailed, err.Error())
+	}
+
+	if statusErr := r.updateStatus(instance, append(observables(storage, owned), extras), err); statusErr != nil {
+		reqLogger.Error(statusErr, "Failed to update the Nfs status")
+		if err == nil {
+			err = statusErr
+		}
+	}
+	if resources.IsConflict(err) {
+		// A conflict is reported on the status, it's not solved retrying right away
+		reqLogger.Info("Conflict with resources of other Nfs", "error", err.Error())
+		return reconcile.Result{RequeueAfter: requeueAfter}, nil
 	}
-	result, err = nfsprovisioner.New(instance, r.client, r.scheme, log).Reconcile()
 	if err != nil {
 		return result, err
 	}
 
-	// resources := []resources.Reconcilable{}
-	// resources = append(resources, nfsprovisioner.Resources(instance, r.client, r.scheme, log)...)
-	// resources = append(resources, vpcblockbackend.Resources(instance, r.client, r.scheme, log)...)
+	// The owned resources may take a while to be ready, check them again later
+	if instance.Status.Status != ibmcloudv1alpha1.PhaseReady {
+		return reconcile.Result{RequeueAfter: requeueAfter}, nil
+	}
 
-	// for _, resource := range resources {
-	// 	result, err := resource.Reconcile()
-	// 	if err != nil {
-	// 		return result, err
-	// 	}
-	// }
+	// The filesystem usage is collected again after the interval, if enabled
+	return reconcile.Result{RequeueAfter: usageInterval(instance)}, nil
+}
 
-	return reconcile.Result{}, nil
+// newResources creates the backing storage and the NFS provisioner of the Nfs,
+// rendered from the given manifests templates
+func (r *ReconcileNfs) newResources(instance *ibmcloudv1alpha1.Nfs, manifests resources.Manifests) (backend.Backend, *nfsprovisioner.Resources, error) {
+	storage, err := backend.New(instance, manifests, r.client, r.scheme, r.recorder, log)
+	if err != nil {
+		return nil, nil, err
+	}
+	provisioner, err := nfsprovisioner.New(instance, storage.VolumeSource(), manifests, r.client, r.scheme, r.recorder, log)
+	if err != nil {
+		return nil, nil, err
+	}
+	return storage, provisioner, nil
 }
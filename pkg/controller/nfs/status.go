@@ -0,0 +1,95 @@
+package nfs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	"github.com/johandry/nfs-operator/pkg/resources/backend"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// observables returns the backing storage and the owned resources that can
+// report their state on the Nfs status
+func observables(storage backend.Backend, owned []resources.Reconcilable) []resources.Observable {
+	list := []resources.Observable{}
+	if o, ok := storage.(resources.Observable); ok {
+		list = append(list, o)
+	}
+	for _, res := range owned {
+		if o, ok := res.(resources.Observable); ok {
+			list = append(list, o)
+		}
+	}
+	return list
+}
+
+// updateStatus observes the given resources to set the conditions and phase of
+// the Nfs instance. The Ready condition is True only if the reconciliation
+// succeeded and every condition in ReadyConditions is True. The filesystem usage
+// is collected on its own interval. The status is written through the status
+// subresource only if it changed, the metrics of the Nfs are always updated
+func (r *ReconcileNfs) updateStatus(instance *ibmcloudv1alpha1.Nfs, observables []resources.Observable, reconcileErr error) error {
+	st := instance.Status.DeepCopy()
+	st.ObservedGeneration = instance.Generation
+
+	for _, o := range observables {
+		if err := o.Observe(st); err != nil {
+			return err
+		}
+	}
+	r.observeUsage(instance, st)
+
+	ready := status.Condition{
+		Type:    ibmcloudv1alpha1.ConditionReady,
+		Status:  corev1.ConditionTrue,
+		Reason:  "Ready",
+		Message: "the NFS provisioner is ready",
+	}
+	st.Status = ibmcloudv1alpha1.PhaseReady
+
+	notReady := []string{}
+	for _, condType := range ibmcloudv1alpha1.ReadyConditions {
+		if !st.Conditions.IsTrueFor(condType) {
+			notReady = append(notReady, string(condType))
+		}
+	}
+
+	if len(notReady) != 0 {
+		ready.Status = corev1.ConditionFalse
+		ready.Reason = "NotReady"
+		ready.Message = fmt.Sprintf("waiting for %s", strings.Join(notReady, ", "))
+		st.Status = ibmcloudv1alpha1.PhasePending
+	}
+
+	if reconcileErr != nil {
+		ready.Status = corev1.ConditionFalse
+		ready.Reason = "ReconcileFailed"
+		if resources.IsConflict(reconcileErr) {
+			ready.Reason = "Conflict"
+		}
+		if errors.IsInvalid(reconcileErr) {
+			ready.Reason = "InvalidSpec"
+		}
+		if resources.IsTemplateError(reconcileErr) {
+			ready.Reason = "InvalidTemplate"
+		}
+		ready.Message = reconcileErr.Error()
+		st.Status = ibmcloudv1alpha1.PhaseFailed
+	}
+
+	st.Conditions.SetCondition(ready)
+	r.updateMetrics(instance, st, observables)
+
+	if equality.Semantic.DeepEqual(instance.Status, *st) {
+		return nil
+	}
+
+	instance.Status = *st
+	return r.client.Status().Update(context.TODO(), instance)
+}
@@ -0,0 +1,187 @@
+package nfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultUsageInterval is the time between collections of the filesystem usage
+// if the Nfs does not set it
+const defaultUsageInterval = time.Minute
+
+// statsSummary is the summary of the kubelet stats API, only with the volume
+// stats of the Pods
+type statsSummary struct {
+	Pods []podStats `json:"pods"`
+}
+
+type podStats struct {
+	PodRef struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"podRef"`
+	VolumeStats []volumeStats `json:"volume,omitempty"`
+}
+
+type volumeStats struct {
+	Name           string  `json:"name"`
+	AvailableBytes *uint64 `json:"availableBytes,omitempty"`
+	CapacityBytes  *uint64 `json:"capacityBytes,omitempty"`
+	UsedBytes      *uint64 `json:"usedBytes,omitempty"`
+	Inodes         *uint64 `json:"inodes,omitempty"`
+	InodesFree     *uint64 `json:"inodesFree,omitempty"`
+	InodesUsed     *uint64 `json:"inodesUsed,omitempty"`
+}
+
+// usageInterval returns the time between collections of the filesystem usage
+// of the Nfs, zero if it's disabled or the operator does not collect it
+func usageInterval(instance *ibmcloudv1alpha1.Nfs) time.Duration {
+	usage := instance.Spec.Usage
+	if usage.Disabled || !ibmcloudv1alpha1.UsageCollection {
+		return 0
+	}
+	if usage.Interval != nil {
+		return usage.Interval.Duration
+	}
+	return defaultUsageInterval
+}
+
+// observeUsage sets on the status the filesystem usage of the exported volume,
+// collected again once the interval passed since the last collection, and the
+// StorageUsageHigh condition. If the usage cannot be collected the last one is
+// kept, the backing storage not reported by the kubelet, like a host path, has
+// no usage. The capacity is the capacity of the filesystem if the backing
+// storage does not report it
+func (r *ReconcileNfs) observeUsage(instance *ibmcloudv1alpha1.Nfs, st *ibmcloudv1alpha1.NfsStatus) {
+	interval := usageInterval(instance)
+	if interval == 0 {
+		st.Usage = nil
+		st.Conditions.RemoveCondition(ibmcloudv1alpha1.ConditionStorageUsageHigh)
+		return
+	}
+
+	if st.Usage == nil || time.Since(st.Usage.LastUpdateTime.Time) >= interval {
+		usage, err := r.collectUsage(instance)
+		if err != nil {
+			log.Info("Failed to collect the filesystem usage", "Request.Namespace", instance.Namespace, "Request.Name", instance.Name, "error", err.Error())
+		}
+		if usage != nil {
+			st.Usage = usage
+		}
+	}
+	if st.Usage != nil && len(st.Capacity) == 0 {
+		st.Capacity = st.Usage.Capacity.String()
+	}
+
+	r.observeUsageThreshold(instance, st)
+}
+
+// observeUsageThreshold sets the StorageUsageHigh condition if the Nfs has a
+// warning threshold, a Warning Event is emitted when the threshold is reached
+func (r *ReconcileNfs) observeUsageThreshold(instance *ibmcloudv1alpha1.Nfs, st *ibmcloudv1alpha1.NfsStatus) {
+	threshold := instance.Spec.Usage.WarningThreshold
+	if threshold == nil || st.Usage == nil {
+		st.Conditions.RemoveCondition(ibmcloudv1alpha1.ConditionStorageUsageHigh)
+		return
+	}
+
+	usedPercent := st.Usage.UsedPercent
+	inodesPercent := percent(st.Usage.InodesUsed, st.Usage.Inodes)
+	cond := status.Condition{
+		Type:    ibmcloudv1alpha1.ConditionStorageUsageHigh,
+		Status:  corev1.ConditionFalse,
+		Reason:  "BelowThreshold",
+		Message: fmt.Sprintf("%d%% of the space and %d%% of the inodes are used, below the threshold of %d%%", usedPercent, inodesPercent, *threshold),
+	}
+	if usedPercent >= *threshold || inodesPercent >= *threshold {
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = "ThresholdReached"
+		cond.Message = fmt.Sprintf("%d%% of the space and %d%% of the inodes are used, the threshold is %d%%", usedPercent, inodesPercent, *threshold)
+		if !st.Conditions.IsTrueFor(ibmcloudv1alpha1.ConditionStorageUsageHigh) {
+			r.recorder.Event(instance, corev1.EventTypeWarning, resources.ReasonStorageUsageHigh, cond.Message)
+		}
+	}
+
+	st.Conditions.SetCondition(cond)
+}
+
+// collectUsage returns the filesystem usage of the exported volume of a running
+// NFS provisioner Pod, from the kubelet stats of its node requested through the
+// API server proxy. It's nil if there is no running Pod or the kubelet does not
+// report the volume
+func (r *ReconcileNfs) collectUsage(instance *ibmcloudv1alpha1.Nfs) (*ibmcloudv1alpha1.UsageStatus, error) {
+	pods := &corev1.PodList{}
+	if err := r.client.List(context.TODO(), pods, client.InNamespace(instance.Namespace), client.MatchingLabels{"app": resources.AppName(instance)}); err != nil {
+		return nil, err
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning || len(pod.Spec.NodeName) == 0 {
+			continue
+		}
+
+		data, err := r.kubeClient.CoreV1().RESTClient().Get().
+			Resource("nodes").Name(pod.Spec.NodeName).
+			SubResource("proxy").Suffix("stats", "summary").
+			DoRaw(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("fail to get the kubelet stats of the node %s. %s", pod.Spec.NodeName, err)
+		}
+		summary := statsSummary{}
+		if err := json.Unmarshal(data, &summary); err != nil {
+			return nil, fmt.Errorf("invalid kubelet stats of the node %s. %s", pod.Spec.NodeName, err)
+		}
+		return volumeUsage(summary, pod), nil
+	}
+
+	return nil, nil
+}
+
+// volumeUsage returns the usage of the exported volume of the given Pod in the
+// kubelet stats, or nil if it's not reported
+func volumeUsage(summary statsSummary, pod *corev1.Pod) *ibmcloudv1alpha1.UsageStatus {
+	for _, ps := range summary.Pods {
+		if ps.PodRef.Name != pod.Name || ps.PodRef.Namespace != pod.Namespace {
+			continue
+		}
+		for _, vs := range ps.VolumeStats {
+			if vs.Name != resources.ExportVolumeName || vs.CapacityBytes == nil || vs.UsedBytes == nil || vs.AvailableBytes == nil {
+				continue
+			}
+			usage := &ibmcloudv1alpha1.UsageStatus{
+				Capacity:       *resource.NewQuantity(int64(*vs.CapacityBytes), resource.BinarySI),
+				Used:           *resource.NewQuantity(int64(*vs.UsedBytes), resource.BinarySI),
+				Available:      *resource.NewQuantity(int64(*vs.AvailableBytes), resource.BinarySI),
+				UsedPercent:    percent(int64(*vs.UsedBytes), int64(*vs.CapacityBytes)),
+				LastUpdateTime: metav1.Now(),
+			}
+			if vs.Inodes != nil && vs.InodesUsed != nil && vs.InodesFree != nil {
+				usage.Inodes = int64(*vs.Inodes)
+				usage.InodesUsed = int64(*vs.InodesUsed)
+				usage.InodesFree = int64(*vs.InodesFree)
+			}
+			return usage
+		}
+	}
+	return nil
+}
+
+// percent returns the percentage of used in total, rounded up so a nearly full
+// filesystem is not reported below the threshold
+func percent(used, total int64) int32 {
+	if total <= 0 {
+		return 0
+	}
+	return int32((used*100 + total - 1) / total)
+}
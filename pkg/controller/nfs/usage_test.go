@@ -0,0 +1,297 @@
+package nfs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestUsageInterval(t *testing.T) {
+	tests := []struct {
+		name       string
+		collection bool
+		usage      ibmcloudv1alpha1.UsageSpec
+		want       time.Duration
+	}{
+		{
+			name:       "default interval",
+			collection: true,
+			want:       defaultUsageInterval,
+		},
+		{
+			name:       "interval of the Nfs",
+			collection: true,
+			usage:      ibmcloudv1alpha1.UsageSpec{Interval: &metav1.Duration{Duration: 5 * time.Minute}},
+			want:       5 * time.Minute,
+		},
+		{
+			name:       "disabled by the Nfs",
+			collection: true,
+			usage:      ibmcloudv1alpha1.UsageSpec{Disabled: true},
+		},
+		{
+			name:  "not collected by the operator",
+			usage: ibmcloudv1alpha1.UsageSpec{Interval: &metav1.Duration{Duration: 5 * time.Minute}},
+		},
+	}
+	defer func(enabled bool) { ibmcloudv1alpha1.UsageCollection = enabled }(ibmcloudv1alpha1.UsageCollection)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ibmcloudv1alpha1.UsageCollection = tt.collection
+			instance := &ibmcloudv1alpha1.Nfs{Spec: ibmcloudv1alpha1.NfsSpec{Usage: tt.usage}}
+			if got := usageInterval(instance); got != tt.want {
+				t.Errorf("usageInterval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// statsPayload is a kubelet stats summary of the node with the NFS provisioner
+// Pod, the exported volume has 30% of its 10Gi and 50% of its inodes used
+const statsPayload = `{
+  "node": {"nodeName": "node-1"},
+  "pods": [
+    {
+      "podRef": {"name": "other", "namespace": "test", "uid": "2"},
+      "volume": [{"name": "export-volume", "capacityBytes": 1, "usedBytes": 1, "availableBytes": 0}]
+    },
+    {
+      "podRef": {"name": "nfs-nfs-provisioner-0", "namespace": "test", "uid": "1"},
+      "volume": [
+        {"name": "ganesha-config", "capacityBytes": 1, "usedBytes": 1, "availableBytes": 0},
+        {"name": "export-volume", "capacityBytes": 10737418240, "usedBytes": 3221225472, "availableBytes": 7516192768, "inodes": 1000, "inodesFree": 500, "inodesUsed": 500}
+      ]
+    }
+  ]
+}`
+
+// newProvisionerPod returns the running NFS provisioner Pod of the Nfs nfs
+func newProvisionerPod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "nfs-nfs-provisioner-0", Namespace: "test", Labels: map[string]string{"app": "nfs-nfs-provisioner"}},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func TestVolumeUsage(t *testing.T) {
+	summary := statsSummary{}
+	if err := json.Unmarshal([]byte(statsPayload), &summary); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		pod           *corev1.Pod
+		want          string
+		wantPercent   int32
+		wantInodes    int64
+		wantAvailable string
+	}{
+		{
+			name:          "exported volume",
+			pod:           newProvisionerPod(),
+			want:          "10Gi",
+			wantPercent:   30,
+			wantInodes:    1000,
+			wantAvailable: "7Gi",
+		},
+		{
+			name: "Pod not reported",
+			pod:  &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "nfs-nfs-provisioner-1", Namespace: "test"}},
+		},
+		{
+			name: "Pod in other namespace",
+			pod:  &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "nfs-nfs-provisioner-0", Namespace: "other"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := volumeUsage(summary, tt.pod)
+			if len(tt.want) == 0 {
+				if got != nil {
+					t.Errorf("volumeUsage() = %v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("volumeUsage() = nil, want the capacity %s", tt.want)
+			}
+			if got.Capacity.Cmp(resource.MustParse(tt.want)) != 0 || got.Available.Cmp(resource.MustParse(tt.wantAvailable)) != 0 || got.UsedPercent != tt.wantPercent || got.Inodes != tt.wantInodes {
+				t.Errorf("volumeUsage() = capacity %s, available %s, %d%% used, %d inodes, want %s, %s, %d%%, %d", got.Capacity.String(), got.Available.String(), got.UsedPercent, got.Inodes, tt.want, tt.wantAvailable, tt.wantPercent, tt.wantInodes)
+			}
+		})
+	}
+
+	// a volume without the space is not reported, like a host path
+	partial := statsSummary{}
+	if err := json.Unmarshal([]byte(`{"pods":[{"podRef":{"name":"nfs-nfs-provisioner-0","namespace":"test"},"volume":[{"name":"export-volume","inodes":10}]}]}`), &partial); err != nil {
+		t.Fatal(err)
+	}
+	if got := volumeUsage(partial, newProvisionerPod()); got != nil {
+		t.Errorf("volumeUsage() without space = %v, want nil", got)
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		used, total int64
+		want        int32
+	}{
+		{used: 0, total: 0, want: 0},
+		{used: 0, total: 100, want: 0},
+		{used: 30, total: 100, want: 30},
+		{used: 1, total: 3, want: 34},
+		{used: 999, total: 1000, want: 100},
+		{used: 1000, total: 1000, want: 100},
+	}
+	for _, tt := range tests {
+		if got := percent(tt.used, tt.total); got != tt.want {
+			t.Errorf("percent(%d, %d) = %d, want %d", tt.used, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestObserveUsage(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v1/nodes/node-1/proxy/stats/summary" {
+			http.NotFound(w, req)
+			return
+		}
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(statsPayload))
+	}))
+	defer server.Close()
+	kubeClient, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	high := status.Condition{Type: ibmcloudv1alpha1.ConditionStorageUsageHigh, Status: corev1.ConditionTrue, Reason: "ThresholdReached"}
+	tests := []struct {
+		name         string
+		collection   bool
+		threshold    *int32
+		conditions   status.Conditions
+		wantRequest  bool
+		wantUsage    bool
+		wantCond     corev1.ConditionStatus
+		wantEvent    bool
+		wantCapacity string
+	}{
+		{
+			name:       "not collected by the operator",
+			threshold:  int32Ptr(10),
+			conditions: status.Conditions{high},
+		},
+		{
+			name:         "without threshold",
+			collection:   true,
+			wantRequest:  true,
+			wantUsage:    true,
+			wantCapacity: "10Gi",
+		},
+		{
+			name:         "below the threshold",
+			collection:   true,
+			threshold:    int32Ptr(90),
+			wantRequest:  true,
+			wantUsage:    true,
+			wantCond:     corev1.ConditionFalse,
+			wantCapacity: "10Gi",
+		},
+		{
+			name:         "threshold reached",
+			collection:   true,
+			threshold:    int32Ptr(30),
+			wantRequest:  true,
+			wantUsage:    true,
+			wantCond:     corev1.ConditionTrue,
+			wantEvent:    true,
+			wantCapacity: "10Gi",
+		},
+		{
+			name:         "threshold already reached",
+			collection:   true,
+			threshold:    int32Ptr(30),
+			conditions:   status.Conditions{high},
+			wantRequest:  true,
+			wantUsage:    true,
+			wantCond:     corev1.ConditionTrue,
+			wantCapacity: "10Gi",
+		},
+		{
+			name:         "threshold reached by the inodes",
+			collection:   true,
+			threshold:    int32Ptr(40),
+			wantRequest:  true,
+			wantUsage:    true,
+			wantCond:     corev1.ConditionTrue,
+			wantEvent:    true,
+			wantCapacity: "10Gi",
+		},
+	}
+	defer func(enabled bool) { ibmcloudv1alpha1.UsageCollection = enabled }(ibmcloudv1alpha1.UsageCollection)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ibmcloudv1alpha1.UsageCollection = tt.collection
+			requests = 0
+			scheme := runtime.NewScheme()
+			if err := clientgoscheme.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			recorder := record.NewFakeRecorder(10)
+			r := &ReconcileNfs{
+				client:     fake.NewFakeClientWithScheme(scheme, newProvisionerPod()),
+				scheme:     scheme,
+				recorder:   recorder,
+				kubeClient: kubeClient,
+			}
+			instance := &ibmcloudv1alpha1.Nfs{ObjectMeta: metav1.ObjectMeta{Name: "nfs", Namespace: "test"}}
+			instance.Spec.Usage.WarningThreshold = tt.threshold
+			st := &ibmcloudv1alpha1.NfsStatus{Conditions: tt.conditions}
+			if !tt.collection {
+				st.Usage = &ibmcloudv1alpha1.UsageStatus{UsedPercent: 50}
+			}
+
+			r.observeUsage(instance, st)
+
+			if got := requests != 0; got != tt.wantRequest {
+				t.Errorf("observeUsage() requested the kubelet stats = %v, want %v", got, tt.wantRequest)
+			}
+			if got := st.Usage != nil; got != tt.wantUsage {
+				t.Errorf("observeUsage() usage = %v, want it reported %v", st.Usage, tt.wantUsage)
+			}
+			if st.Capacity != tt.wantCapacity {
+				t.Errorf("observeUsage() capacity = %q, want %q", st.Capacity, tt.wantCapacity)
+			}
+			cond := st.Conditions.GetCondition(ibmcloudv1alpha1.ConditionStorageUsageHigh)
+			switch {
+			case len(tt.wantCond) == 0 && cond != nil:
+				t.Errorf("observeUsage() condition = %v, want it removed", cond)
+			case len(tt.wantCond) != 0 && (cond == nil || cond.Status != tt.wantCond):
+				t.Errorf("observeUsage() condition = %v, want %s", cond, tt.wantCond)
+			}
+			if got := len(recorder.Events) != 0; got != tt.wantEvent {
+				t.Errorf("observeUsage() emitted an Event = %v, want %v", got, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func int32Ptr(i int32) *int32 { return &i }
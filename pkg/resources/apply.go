@@ -0,0 +1,102 @@
+package resources
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// FieldManager is the name of the operator as manager of the fields it applies
+const FieldManager = "nfs-operator"
+
+// ServerSideApply creates or updates the desired object with server-side apply.
+// The operator owns only the fields set on the desired object, the fields set
+// by users or other controllers are preserved. The applied fields are forced,
+// so a field changed by hand or by other manager is restored, but an existing
+// object not owned by the Nfs is not taken and a ConflictError is returned. The
+// result, created, updated or skipped if nothing changed, is recorded in the
+// metrics and, if something changed, as an Event on the owner
+func (r Resource) ServerSideApply(desired runtime.Object) error {
+	gvk, err := apiutil.GVKForObject(desired, r.Scheme)
+	if err != nil {
+		return err
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(desired.DeepCopyObject())
+	if err != nil {
+		return err
+	}
+	obj := &unstructured.Unstructured{Object: prune(content).(map[string]interface{})}
+	delete(obj.Object, "status")
+	obj.SetGroupVersionKind(gvk)
+	// the apply request cannot have the managed fields and has to apply to the
+	// latest version of the object
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+
+	// the version before the apply tells if it's created, updated or unchanged.
+	// It's read as unstructured, the manager client reads it from the API server
+	// instead of the cache, which may not have the latest version yet
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(gvk)
+	getErr := r.Client.Get(context.TODO(), client.ObjectKey{Namespace: obj.GetNamespace(), Name: obj.GetName()}, current)
+	if getErr == nil && !r.IsOwned(current) {
+		return NewConflictError(gvk.Kind, obj.GetName(), "it's not owned by the Nfs")
+	}
+
+	err = r.Client.Patch(context.TODO(), obj, client.Apply, client.ForceOwnership, client.FieldOwner(FieldManager))
+	if err != nil {
+		r.Log.Error(err, "Failed to apply the resource")
+		return err
+	}
+
+	result := ""
+	switch {
+	case errors.IsNotFound(getErr):
+		result = ResultCreated
+	case getErr != nil:
+		// unknown previous version, the result is not recorded
+	default:
+		result = ResultUpdated
+		if current.GetResourceVersion() == obj.GetResourceVersion() {
+			result = ResultSkipped
+		}
+	}
+	if len(result) != 0 {
+		RecordResult(gvk.Kind, result)
+	}
+	switch result {
+	case ResultCreated:
+		r.Eventf(corev1.EventTypeNormal, ReasonCreated, "Created the %s %s", gvk.Kind, obj.GetName())
+	case ResultUpdated:
+		r.Eventf(corev1.EventTypeNormal, ReasonUpdated, "Updated the %s %s", gvk.Kind, obj.GetName())
+	}
+
+	r.Log.Info("Applied the resource", "Resource.ResourceVersion", obj.GetResourceVersion(), "Resource.Result", result)
+	return nil
+}
+
+// prune removes the null values, so the operator does not own fields it does
+// not set, like the creation timestamp
+func prune(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if e == nil {
+				delete(t, k)
+				continue
+			}
+			t[k] = prune(e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = prune(e)
+		}
+	}
+	return v
+}
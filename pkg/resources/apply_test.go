@@ -0,0 +1,164 @@
+package resources
+
+import (
+	"context"
+	"testing"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// applyClient is a fake client that handles the apply patches, which the fake
+// client does not support, merging the applied object into the current one.
+// The object is not updated if nothing changed, like the API server does
+type applyClient struct {
+	client.Client
+	patched bool
+	forced  bool
+}
+
+func (c *applyClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	options := &client.PatchOptions{}
+	options.ApplyOptions(opts)
+	c.patched = true
+	c.forced = options.Force != nil && *options.Force
+
+	applied := obj.(*unstructured.Unstructured)
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(applied.GroupVersionKind())
+	err := c.Client.Get(ctx, client.ObjectKey{Namespace: applied.GetNamespace(), Name: applied.GetName()}, current)
+	if errors.IsNotFound(err) {
+		return c.Client.Create(ctx, applied)
+	}
+	if err != nil {
+		return err
+	}
+
+	merged := current.DeepCopy()
+	merge(merged.Object, applied.Object)
+	if !equality.Semantic.DeepEqual(merged.Object, current.Object) {
+		if err := c.Client.Update(ctx, merged); err != nil {
+			return err
+		}
+	}
+	merged.DeepCopyInto(applied)
+	return nil
+}
+
+// merge sets the fields of src on dst
+func merge(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if m, ok := v.(map[string]interface{}); ok {
+			if d, ok := dst[k].(map[string]interface{}); ok {
+				merge(d, m)
+				continue
+			}
+		}
+		dst[k] = runtime.DeepCopyJSONValue(v)
+	}
+}
+
+func TestServerSideApply(t *testing.T) {
+	owner := &ibmcloudv1alpha1.Nfs{
+		ObjectMeta: metav1.ObjectMeta{Name: "nfs", Namespace: "test", UID: "uid"},
+	}
+	ownerLabels := map[string]string{LabelOwnerName: "nfs", LabelOwnerNamespace: "test"}
+	newConfigMap := func(labels map[string]string, value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "test", Labels: labels},
+			Data:       map[string]string{"key": value},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		existing    *corev1.ConfigMap
+		wantErr     bool
+		wantPatched bool
+		wantEvent   string
+		wantValue   string
+	}{
+		{
+			name:        "created",
+			wantPatched: true,
+			wantEvent:   "Normal Created Created the ConfigMap config",
+			wantValue:   "desired",
+		},
+		{
+			name:        "changed by hand is restored",
+			existing:    newConfigMap(ownerLabels, "edited"),
+			wantPatched: true,
+			wantEvent:   "Normal Updated Updated the ConfigMap config",
+			wantValue:   "desired",
+		},
+		{
+			name:        "unchanged is skipped",
+			existing:    newConfigMap(ownerLabels, "desired"),
+			wantPatched: true,
+			wantValue:   "desired",
+		},
+		{
+			name:      "not owned",
+			existing:  newConfigMap(nil, "other"),
+			wantErr:   true,
+			wantValue: "other",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := clientgoscheme.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			objs := []runtime.Object{}
+			if tt.existing != nil {
+				objs = append(objs, tt.existing)
+			}
+			c := &applyClient{Client: fake.NewFakeClientWithScheme(scheme, objs...)}
+			recorder := record.NewFakeRecorder(10)
+			r := New(owner, c, scheme, recorder, logf.Log)
+
+			err := r.ServerSideApply(newConfigMap(ownerLabels, "desired"))
+			if tt.wantErr != IsConflict(err) || (!tt.wantErr && err != nil) {
+				t.Fatalf("ServerSideApply() error = %v, want a conflict %v", err, tt.wantErr)
+			}
+			if c.patched != tt.wantPatched {
+				t.Errorf("ServerSideApply() patched = %v, want %v", c.patched, tt.wantPatched)
+			}
+			if c.patched && !c.forced {
+				t.Errorf("ServerSideApply() does not force the ownership of the applied fields")
+			}
+
+			event := ""
+			select {
+			case event = <-recorder.Events:
+			default:
+			}
+			if event != tt.wantEvent {
+				t.Errorf("ServerSideApply() event = %q, want %q", event, tt.wantEvent)
+			}
+
+			found := &corev1.ConfigMap{}
+			if err := c.Get(context.TODO(), client.ObjectKey{Namespace: "test", Name: "config"}, found); err != nil {
+				t.Fatal(err)
+			}
+			if got := found.Data["key"]; got != tt.wantValue {
+				t.Errorf("ServerSideApply() value = %q, want %q", got, tt.wantValue)
+			}
+		})
+	}
+}
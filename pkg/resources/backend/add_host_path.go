@@ -0,0 +1,18 @@
+package backend
+
+import (
+	"github.com/go-logr/logr"
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	hostpath "github.com/johandry/nfs-operator/pkg/resources/backend/host-path"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func init() {
+	// Factories is the registry of the backends by type
+	Factories[ibmcloudv1alpha1.BackendHostPath] = func(owner *ibmcloudv1alpha1.Nfs, manifests resources.Manifests, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) (Backend, error) {
+		return hostpath.New(owner, client, scheme, recorder, log), nil
+	}
+}
@@ -0,0 +1,49 @@
+package backend
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Backend is the backing storage where the NFS Provisioner stores the exported
+// data
+type Backend interface {
+	// Resources returns the group of reconcilable resources of the backend
+	Resources() []resources.Reconcilable
+	// Reconcile creates or updates the resources of the backend
+	Reconcile() (reconcile.Result, error)
+	// VolumeSource returns the volume to mount in the NFS Provisioner for export
+	VolumeSource() corev1.VolumeSource
+}
+
+// Factory creates a Backend for the given Nfs, the resources of the backend are
+// rendered from the given manifests templates
+type Factory func(owner *ibmcloudv1alpha1.Nfs, manifests resources.Manifests, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) (Backend, error)
+
+// Factories is the registry of the backends by type
+var Factories = map[ibmcloudv1alpha1.BackendType]Factory{}
+
+// New creates the Backend of the type set in the Nfs spec, if not set it's a
+// IBM Cloud VPC Block backend. A TemplateError is returned if the manifest
+// template of a resource is invalid
+func New(owner *ibmcloudv1alpha1.Nfs, manifests resources.Manifests, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) (Backend, error) {
+	backendType := owner.Spec.BackingStorage.Type
+	if len(backendType) == 0 {
+		backendType = ibmcloudv1alpha1.BackendVPCBlock
+	}
+
+	factory, ok := Factories[backendType]
+	if !ok {
+		return nil, fmt.Errorf("unknown backing storage type %q", backendType)
+	}
+
+	return factory(owner, manifests, client, scheme, recorder, log)
+}
@@ -0,0 +1,69 @@
+package emptydir
+
+import (
+	"github.com/go-logr/logr"
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ resources.Observable = &Resources{}
+
+// Resources implements the backend.Backend interface
+type Resources struct {
+	owner *ibmcloudv1alpha1.Nfs
+	log   logr.Logger
+}
+
+// New creates a resources group for an ephemeral backing storage, the data is
+// lost when the NFS Provisioner Pod is deleted. It's meant for CI or testing
+func New(owner *ibmcloudv1alpha1.Nfs, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) *Resources {
+	return &Resources{
+		owner: owner,
+		log:   log.WithName("empty-dir"),
+	}
+}
+
+// Resources returns the group of reconcilable resources required to
+// have a NFS Provisioner
+func (r *Resources) Resources() []resources.Reconcilable {
+	return []resources.Reconcilable{}
+}
+
+// Reconcile does nothing, there are no resources to create
+func (r *Resources) Reconcile() (reconcile.Result, error) {
+	return reconcile.Result{}, nil
+}
+
+// VolumeSource returns the volume to export by the NFS Provisioner, limited to
+// the storage size in the spec, if any
+func (r *Resources) VolumeSource() corev1.VolumeSource {
+	emptyDir := &corev1.EmptyDirVolumeSource{}
+	if size, err := resource.ParseQuantity(r.owner.Spec.BackingStorage.StorageSize); err == nil {
+		emptyDir.SizeLimit = &size
+	}
+	return corev1.VolumeSource{
+		EmptyDir: emptyDir,
+	}
+}
+
+// Observe sets the BackingStorageBound condition on the given status, an
+// emptyDir is always available
+func (r *Resources) Observe(st *ibmcloudv1alpha1.NfsStatus) error {
+	st.Conditions.SetCondition(status.Condition{
+		Type:    ibmcloudv1alpha1.ConditionBackingStorageBound,
+		Status:  corev1.ConditionTrue,
+		Reason:  "EmptyDir",
+		Message: "the backing storage is ephemeral, the data is lost when the NFS provisioner is deleted",
+	})
+	st.Capacity = r.owner.Spec.BackingStorage.StorageSize
+	st.AccessMode = ""
+
+	return nil
+}
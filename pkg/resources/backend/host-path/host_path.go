@@ -0,0 +1,83 @@
+package hostpath
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/go-logr/logr"
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ resources.Observable = &Resources{}
+
+const (
+	baseDir = "/var/lib/nfs-operator"
+)
+
+// Resources implements the backend.Backend interface
+type Resources struct {
+	owner *ibmcloudv1alpha1.Nfs
+	log   logr.Logger
+}
+
+// New creates a resources group for a backing storage provided by a directory
+// on the node running the NFS Provisioner. There is nothing to create, the
+// directory is created by the kubelet if it does not exists. It's meant for
+// development or testing clusters like kind or minikube
+func New(owner *ibmcloudv1alpha1.Nfs, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) *Resources {
+	return &Resources{
+		owner: owner,
+		log:   log.WithName("host-path"),
+	}
+}
+
+// Resources returns the group of reconcilable resources required to
+// have a NFS Provisioner
+func (r *Resources) Resources() []resources.Reconcilable {
+	return []resources.Reconcilable{}
+}
+
+// Reconcile does nothing, there are no resources to create
+func (r *Resources) Reconcile() (reconcile.Result, error) {
+	return reconcile.Result{}, nil
+}
+
+// VolumeSource returns the volume to export by the NFS Provisioner
+func (r *Resources) VolumeSource() corev1.VolumeSource {
+	hostPathType := corev1.HostPathDirectoryOrCreate
+	return corev1.VolumeSource{
+		HostPath: &corev1.HostPathVolumeSource{
+			Path: r.path(),
+			Type: &hostPathType,
+		},
+	}
+}
+
+// Observe sets the BackingStorageBound condition on the given status, a host
+// path is always available
+func (r *Resources) Observe(st *ibmcloudv1alpha1.NfsStatus) error {
+	st.Conditions.SetCondition(status.Condition{
+		Type:    ibmcloudv1alpha1.ConditionBackingStorageBound,
+		Status:  corev1.ConditionTrue,
+		Reason:  "HostPath",
+		Message: fmt.Sprintf("the backing storage is the directory %s of the node", r.path()),
+	})
+	st.Capacity = ""
+	st.AccessMode = ""
+
+	return nil
+}
+
+func (r *Resources) path() string {
+	if path := r.owner.Spec.BackingStorage.HostPath; len(path) != 0 {
+		return path
+	}
+	return filepath.Join(baseDir, r.owner.Namespace, r.owner.Name)
+}
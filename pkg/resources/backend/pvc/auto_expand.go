@@ -0,0 +1,107 @@
+package pvc
+
+import (
+	"time"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultAutoExpandCooldown is the minimum time between automatic expansions
+// if the Nfs does not set it
+const defaultAutoExpandCooldown = 15 * time.Minute
+
+// autoExpand returns the next automatic expansion of the claim, or nil if it's
+// not expanded. The claim grows a step, up to the max size, when the used space
+// of the exported volume reaches the trigger percentage. It's not expanded
+// while a resize is in progress, during the cooldown after the last expansion
+// nor with a usage collected before it, that may not have the new size yet
+func (r *ResPersistentVolumeClaim) autoExpand(found *corev1.PersistentVolumeClaim) (*ibmcloudv1alpha1.AutoExpansionStatus, error) {
+	policy := r.Owner.Spec.BackingStorage.AutoExpand
+	usage := r.Owner.Status.Usage
+	if policy == nil || usage == nil || usage.UsedPercent < policy.TriggerPercent {
+		return nil, nil
+	}
+
+	current := found.Spec.Resources.Requests[corev1.ResourceStorage]
+	capacity := found.Status.Capacity[corev1.ResourceStorage]
+	if found.Status.Phase != corev1.ClaimBound || capacity.Cmp(current) < 0 {
+		r.Log.Info("Skip auto expand: Resize in progress")
+		return nil, nil
+	}
+
+	last := r.Owner.Status.AutoExpansion
+	count := int32(0)
+	if last != nil {
+		cooldown := defaultAutoExpandCooldown
+		if policy.Cooldown != nil {
+			cooldown = policy.Cooldown.Duration
+		}
+		if time.Since(last.LastExpansionTime.Time) < cooldown {
+			r.Log.Info("Skip auto expand: Cooldown after the last expansion", "LastExpansionTime", last.LastExpansionTime.String())
+			return nil, nil
+		}
+		if !usage.LastUpdateTime.After(last.LastExpansionTime.Time) {
+			r.Log.Info("Skip auto expand: Waiting for the usage after the last expansion")
+			return nil, nil
+		}
+		count = last.Count
+	}
+
+	// the webhook rejects an invalid step or max size, without it the error is
+	// reported on the status by the controller
+	step, err := resource.ParseQuantity(policy.Step)
+	if err != nil {
+		return nil, err
+	}
+	maxSize, err := resource.ParseQuantity(policy.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+	if current.Cmp(maxSize) >= 0 {
+		r.Log.Info("Skip auto expand: The claim has the max size", "MaxSize", maxSize.String())
+		r.Eventf(corev1.EventTypeWarning, resources.ReasonAutoExpandLimitReached, "The PersistentVolumeClaim %s has the max size %s, %d%% of the space is used", found.Name, maxSize.String(), usage.UsedPercent)
+		return nil, nil
+	}
+
+	allowed, err := r.allowVolumeExpansion(found)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		r.Log.Info("Skip auto expand: The storage class does not allow volume expansion")
+		return nil, nil
+	}
+
+	size := current.DeepCopy()
+	size.Add(step)
+	if size.Cmp(maxSize) > 0 {
+		size = maxSize
+	}
+
+	return &ibmcloudv1alpha1.AutoExpansionStatus{
+		Count:             count + 1,
+		LastExpansionTime: metav1.Now(),
+		From:              current.DeepCopy(),
+		To:                size,
+	}, nil
+}
+
+// expandedSize returns the size requested by the latest automatic expansion, if
+// the Nfs has the autoExpand policy. The claim is not shrunk back to the size
+// in the spec
+func (r *ResPersistentVolumeClaim) expandedSize() (resource.Quantity, bool) {
+	if r.Owner.Spec.BackingStorage.AutoExpand == nil {
+		return resource.Quantity{}, false
+	}
+	if r.expansion != nil {
+		return r.expansion.To, true
+	}
+	if last := r.Owner.Status.AutoExpansion; last != nil {
+		return last.To, true
+	}
+	return resource.Quantity{}, false
+}
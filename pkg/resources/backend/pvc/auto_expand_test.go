@@ -0,0 +1,228 @@
+package pvc
+
+import (
+	"testing"
+	"time"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// newExpansion returns a previous automatic expansion done the given time ago
+func newExpansion(count int32, ago time.Duration, from, to string) *ibmcloudv1alpha1.AutoExpansionStatus {
+	return &ibmcloudv1alpha1.AutoExpansionStatus{
+		Count:             count,
+		LastExpansionTime: metav1.NewTime(time.Now().Add(-ago)),
+		From:              resource.MustParse(from),
+		To:                resource.MustParse(to),
+	}
+}
+
+func TestAutoExpand(t *testing.T) {
+	policy := ibmcloudv1alpha1.AutoExpandSpec{TriggerPercent: 80, Step: "5Gi", MaxSize: "30Gi"}
+	cooldown := &metav1.Duration{Duration: time.Minute}
+
+	tests := []struct {
+		name           string
+		policy         *ibmcloudv1alpha1.AutoExpandSpec
+		usedPercent    int32
+		usageAge       time.Duration
+		last           *ibmcloudv1alpha1.AutoExpansionStatus
+		claim          *corev1.PersistentVolumeClaim
+		allowExpansion bool
+		wantErr        bool
+		wantTo         string
+		wantCount      int32
+		wantEvent      string
+	}{
+		{
+			name:           "without policy",
+			usedPercent:    95,
+			claim:          newClaim("block", "10Gi", "10Gi"),
+			allowExpansion: true,
+		},
+		{
+			name:           "below the trigger",
+			policy:         &policy,
+			usedPercent:    79,
+			claim:          newClaim("block", "10Gi", "10Gi"),
+			allowExpansion: true,
+		},
+		{
+			name:           "trigger reached",
+			policy:         &policy,
+			usedPercent:    80,
+			claim:          newClaim("block", "10Gi", "10Gi"),
+			allowExpansion: true,
+			wantTo:         "15Gi",
+			wantCount:      1,
+		},
+		{
+			name:           "resize in progress",
+			policy:         &policy,
+			usedPercent:    90,
+			claim:          newClaim("block", "15Gi", "10Gi"),
+			allowExpansion: true,
+		},
+		{
+			name:           "claim not bound",
+			policy:         &policy,
+			usedPercent:    90,
+			claim:          newClaim("block", "10Gi", ""),
+			allowExpansion: true,
+		},
+		{
+			name:           "cooldown after the last expansion",
+			policy:         &policy,
+			usedPercent:    90,
+			last:           newExpansion(1, 5*time.Minute, "10Gi", "15Gi"),
+			claim:          newClaim("block", "15Gi", "15Gi"),
+			allowExpansion: true,
+		},
+		{
+			name:           "usage collected before the last expansion",
+			policy:         &ibmcloudv1alpha1.AutoExpandSpec{TriggerPercent: 80, Step: "5Gi", MaxSize: "30Gi", Cooldown: cooldown},
+			usedPercent:    90,
+			usageAge:       10 * time.Minute,
+			last:           newExpansion(1, 5*time.Minute, "10Gi", "15Gi"),
+			claim:          newClaim("block", "15Gi", "15Gi"),
+			allowExpansion: true,
+		},
+		{
+			name:           "expanded again after the cooldown",
+			policy:         &ibmcloudv1alpha1.AutoExpandSpec{TriggerPercent: 80, Step: "5Gi", MaxSize: "30Gi", Cooldown: cooldown},
+			usedPercent:    90,
+			last:           newExpansion(1, 5*time.Minute, "10Gi", "15Gi"),
+			claim:          newClaim("block", "15Gi", "15Gi"),
+			allowExpansion: true,
+			wantTo:         "20Gi",
+			wantCount:      2,
+		},
+		{
+			name:           "step clamped to the max size",
+			policy:         &policy,
+			usedPercent:    90,
+			claim:          newClaim("block", "28Gi", "28Gi"),
+			allowExpansion: true,
+			wantTo:         "30Gi",
+			wantCount:      1,
+		},
+		{
+			name:           "max size reached",
+			policy:         &policy,
+			usedPercent:    90,
+			claim:          newClaim("block", "30Gi", "30Gi"),
+			allowExpansion: true,
+			wantEvent:      "Warning " + resources.ReasonAutoExpandLimitReached + " The PersistentVolumeClaim nfs-nfs-block has the max size 30Gi, 90% of the space is used",
+		},
+		{
+			name:        "expansion not allowed",
+			policy:      &policy,
+			usedPercent: 90,
+			claim:       newClaim("block", "10Gi", "10Gi"),
+		},
+		{
+			name:           "invalid step",
+			policy:         &ibmcloudv1alpha1.AutoExpandSpec{TriggerPercent: 80, Step: "five", MaxSize: "30Gi"},
+			usedPercent:    90,
+			claim:          newClaim("block", "10Gi", "10Gi"),
+			allowExpansion: true,
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner := &ibmcloudv1alpha1.Nfs{ObjectMeta: metav1.ObjectMeta{Name: "nfs", Namespace: "test"}}
+			owner.Spec.BackingStorage.AutoExpand = tt.policy
+			owner.Status.Usage = &ibmcloudv1alpha1.UsageStatus{
+				UsedPercent:    tt.usedPercent,
+				LastUpdateTime: metav1.NewTime(time.Now().Add(-tt.usageAge)),
+			}
+			owner.Status.AutoExpansion = tt.last
+			r := newTestClaim(t, owner, "10Gi", newStorageClass("block", tt.allowExpansion))
+			recorder := record.NewFakeRecorder(10)
+			r.Recorder = recorder
+
+			got, err := r.autoExpand(tt.claim)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("autoExpand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(tt.wantTo) == 0 {
+				if got != nil {
+					t.Errorf("autoExpand() = %v, want no expansion", got)
+				}
+			} else {
+				from := tt.claim.Spec.Resources.Requests[corev1.ResourceStorage]
+				if got == nil {
+					t.Fatalf("autoExpand() = nil, want an expansion to %s", tt.wantTo)
+				}
+				if got.To.Cmp(resource.MustParse(tt.wantTo)) != 0 || got.From.Cmp(from) != 0 || got.Count != tt.wantCount {
+					t.Errorf("autoExpand() = %d expansions from %s to %s, want %d from %s to %s", got.Count, got.From.String(), got.To.String(), tt.wantCount, from.String(), tt.wantTo)
+				}
+			}
+
+			event := ""
+			select {
+			case event = <-recorder.Events:
+			default:
+			}
+			if event != tt.wantEvent {
+				t.Errorf("autoExpand() event = %q, want %q", event, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestExpandedSize(t *testing.T) {
+	policy := &ibmcloudv1alpha1.AutoExpandSpec{TriggerPercent: 80, Step: "5Gi", MaxSize: "30Gi"}
+	tests := []struct {
+		name      string
+		policy    *ibmcloudv1alpha1.AutoExpandSpec
+		last      *ibmcloudv1alpha1.AutoExpansionStatus
+		expansion *ibmcloudv1alpha1.AutoExpansionStatus
+		want      string
+	}{
+		{
+			name: "without policy",
+			last: newExpansion(1, time.Hour, "10Gi", "15Gi"),
+		},
+		{
+			name:   "never expanded",
+			policy: policy,
+		},
+		{
+			name:   "last expansion",
+			policy: policy,
+			last:   newExpansion(1, time.Hour, "10Gi", "15Gi"),
+			want:   "15Gi",
+		},
+		{
+			name:      "new expansion",
+			policy:    policy,
+			last:      newExpansion(1, time.Hour, "10Gi", "15Gi"),
+			expansion: newExpansion(2, 0, "15Gi", "20Gi"),
+			want:      "20Gi",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner := &ibmcloudv1alpha1.Nfs{ObjectMeta: metav1.ObjectMeta{Name: "nfs", Namespace: "test"}}
+			owner.Spec.BackingStorage.AutoExpand = tt.policy
+			owner.Status.AutoExpansion = tt.last
+			r := newTestClaim(t, owner, "10Gi")
+			r.expansion = tt.expansion
+
+			got, ok := r.expandedSize()
+			if ok != (len(tt.want) != 0) {
+				t.Fatalf("expandedSize() = %s, %v, want %q", got.String(), ok, tt.want)
+			}
+			if ok && got.Cmp(resource.MustParse(tt.want)) != 0 {
+				t.Errorf("expandedSize() = %s, want %s", got.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package pvc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ resources.Reconcilable = &ResPersistentVolumeClaim{}
+var _ resources.Observable = &ResPersistentVolumeClaim{}
+var _ resources.Sized = &ResPersistentVolumeClaim{}
+var _ resources.Finalizable = &ResPersistentVolumeClaim{}
+
+// ResPersistentVolumeClaim is the resource PersistentVolumeClaim
+type ResPersistentVolumeClaim struct {
+	Object              *corev1.PersistentVolumeClaim
+	defaultStorageClass string
+	// expansion is the automatic expansion applied in this reconcile, to record
+	// it on the status
+	expansion *ibmcloudv1alpha1.AutoExpansionStatus
+	// capacity is the capacity of the bound claim observed in this reconcile
+	capacity *resource.Quantity
+	resources.Resource
+}
+
+// manifestPersistentVolumeClaim is the name of the PersistentVolumeClaim
+// manifest template
+const manifestPersistentVolumeClaim = "persistent-volume-claim.yaml"
+
+// contentPersistentVolumeClaim is rendered with the claimData, only the NFS
+// Provisioner Pod mounts the claim
+var contentPersistentVolumeClaim = []byte(`
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: {{ .ClaimName }}
+  namespace: {{ .Namespace }}
+spec:
+  accessModes:
+    - ReadWriteOnce
+{{- if .ClaimStorageClassName }}
+  storageClassName: {{ .ClaimStorageClassName }}
+{{- end }}
+{{- if .StorageSize }}
+  resources:
+    requests:
+      storage: {{ .StorageSize }}
+{{- end }}
+`)
+
+// claimData is the data to render the PersistentVolumeClaim manifest template
+type claimData struct {
+	resources.TemplateData
+	// ClaimStorageClassName is the storage class of the claim, if empty the
+	// cluster default storage class is used
+	ClaimStorageClassName string
+	// StorageSize is the requested size, if empty the claim is not created by
+	// the operator
+	StorageSize string
+}
+
+// PersistentVolumeClaim creates a PersistentVolumeClaim. The claim uses the
+// storage class from the spec or, if not set, the given default storage class.
+// If both are empty the claim uses the cluster default storage class. The claim
+// is rendered from its manifest template
+func PersistentVolumeClaim(owner *ibmcloudv1alpha1.Nfs, defaultStorageClass string, manifests resources.Manifests, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) (*ResPersistentVolumeClaim, error) {
+	res := &ResPersistentVolumeClaim{
+		defaultStorageClass: defaultStorageClass,
+	}
+	res.Resource = resources.New(owner, client, scheme, recorder, log)
+	obj, err := res.newPersistentVolumeClaim(manifests)
+	if err != nil {
+		return nil, err
+	}
+	res.Object = obj
+	apiVersion, kind := resources.GVK(res.Object, res.Scheme)
+	res.Log = res.Log.WithValues("Resource.Name", res.Object.GetName(), "Resource.Namespace", res.Object.GetNamespace(), "Resource.APIVersion", apiVersion, "Resource.Kind", kind)
+
+	return res, nil
+}
+
+// Get returns the Object from the cluster
+func (r *ResPersistentVolumeClaim) Get() (runtime.Object, error) {
+	return r.getPersistentVolumeClaim()
+}
+
+// Type returns an empty object of the type of this resource, to watch it
+func (r *ResPersistentVolumeClaim) Type() runtime.Object {
+	return &corev1.PersistentVolumeClaim{}
+}
+
+// Apply creates or updates the Object with server-side apply
+func (r *ResPersistentVolumeClaim) Apply() error {
+	return r.ServerSideApply(r.Object)
+}
+
+// Reconcile creates the Object if it does not exists and sets the Owner as an
+// owner reference on the Object, unless the deletion policy is to retain it. If
+// the claim exists but it's not owned by the Nfs, it was provided by the user
+// so it's not created nor modified
+func (r *ResPersistentVolumeClaim) Reconcile() (reconcile.Result, error) {
+	if r.Owner == nil {
+		return reconcile.Result{}, fmt.Errorf("the resource %s/%s does not have an owner", r.Object.Namespace, r.Object.Name)
+	}
+	r.Log.Info("Reconciling " + r.Object.Name + " resource")
+
+	found, err := r.getPersistentVolumeClaim()
+	exists, err := resources.Exists(err)
+	if err != nil {
+		r.Log.Error(err, "Failed to reconcile the resource")
+		return reconcile.Result{}, err
+	}
+	if exists && !r.IsOwned(found) {
+		r.Log.Info("Skip reconcile: Resource provided by the user")
+		resources.RecordResult("PersistentVolumeClaim", resources.ResultSkipped)
+		return reconcile.Result{}, nil
+	}
+	if !exists && len(r.Owner.Spec.BackingStorage.StorageSize) == 0 {
+		r.Log.Info("Skip reconcile: Waiting for the user to provide the resource, there is no storage size to create it")
+		resources.RecordResult("PersistentVolumeClaim", resources.ResultSkipped)
+		return reconcile.Result{}, nil
+	}
+
+	// the spec of an existing claim is immutable except for the storage size,
+	// which is only expanded if it's allowed, never shrunk. The immutable fields
+	// applied when it was created are applied again with the same value, the
+	// fields set by the controllers, like the volume name, are left to them. The
+	// size may be expanded by the autoExpand policy
+	if exists {
+		r.expansion, err = r.autoExpand(found)
+		if err != nil {
+			r.Log.Error(err, "Failed to verify the resource auto expansion")
+			return reconcile.Result{}, err
+		}
+		size, _, err := r.resize(found)
+		if err != nil {
+			r.Log.Error(err, "Failed to verify the resource size")
+			return reconcile.Result{}, err
+		}
+		r.Object.Spec.AccessModes = found.Spec.AccessModes
+		r.Object.Spec.StorageClassName = found.Spec.StorageClassName
+		r.Object.Spec.Resources.Requests = corev1.ResourceList{
+			corev1.ResourceStorage: size,
+		}
+	}
+
+	if !r.retain() {
+		if err := controllerutil.SetControllerReference(r.Owner, r.Object, r.Scheme); err != nil {
+			r.Log.Error(err, "Failed to set controller reference to resource")
+			return reconcile.Result{}, err
+		}
+	}
+	if err := r.Apply(); err != nil {
+		r.expansion = nil
+		return reconcile.Result{}, err
+	}
+	if e := r.expansion; e != nil {
+		r.Eventf(corev1.EventTypeNormal, resources.ReasonAutoExpanded, "Expanding the PersistentVolumeClaim %s from %s to %s, %d%% of the space is used", r.Object.Name, e.From.String(), e.To.String(), r.Owner.Status.Usage.UsedPercent)
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// Finalize deletes the claim, or releases it from the Nfs if the deletion
+// policy is to retain it, so the garbage collector does not delete it. A claim
+// provided by the user is never touched
+func (r *ResPersistentVolumeClaim) Finalize() error {
+	found, err := r.getPersistentVolumeClaim()
+	exists, err := resources.Exists(err)
+	if !exists || err != nil {
+		return err
+	}
+
+	if !r.IsOwned(found) {
+		r.Log.Info("Skip finalize: Resource provided by the user")
+		return nil
+	}
+
+	if !r.retain() {
+		return r.Delete(found)
+	}
+
+	ownerRefs := []metav1.OwnerReference{}
+	for _, ref := range found.GetOwnerReferences() {
+		if ref.UID != r.Owner.UID {
+			ownerRefs = append(ownerRefs, ref)
+		}
+	}
+	if len(ownerRefs) == len(found.GetOwnerReferences()) {
+		return nil
+	}
+
+	r.Log.Info("Retaining the resource, removing the owner reference")
+	found.SetOwnerReferences(ownerRefs)
+	if err := r.Client.Update(context.TODO(), found); err != nil {
+		return err
+	}
+	r.Eventf(corev1.EventTypeNormal, resources.ReasonUpdated, "Retained the PersistentVolumeClaim %s, it's not deleted with the Nfs", found.Name)
+	return nil
+}
+
+func (r *ResPersistentVolumeClaim) retain() bool {
+	return r.Owner.Spec.DeletionPolicy == ibmcloudv1alpha1.DeletionPolicyRetain
+}
+
+// Observe sets the BackingStorageBound condition, the capacity and access mode
+// of the claim on the given status. If the claim is owned by the Nfs it also
+// sets the BackingStorageResized condition, the size it's resizing to on the
+// requested capacity, and the automatic expansion applied in this reconcile
+func (r *ResPersistentVolumeClaim) Observe(st *ibmcloudv1alpha1.NfsStatus) error {
+	if r.expansion != nil {
+		st.AutoExpansion = r.expansion.DeepCopy()
+	}
+
+	found, err := r.getPersistentVolumeClaim()
+	exists, err := resources.Exists(err)
+	if err != nil {
+		return err
+	}
+
+	cond := status.Condition{
+		Type:   ibmcloudv1alpha1.ConditionBackingStorageBound,
+		Status: corev1.ConditionFalse,
+	}
+	st.RequestedCapacity = ""
+	r.capacity = nil
+
+	switch {
+	case !exists:
+		cond.Reason = "NotFound"
+		cond.Message = fmt.Sprintf("the claim %s does not exists", r.Object.Name)
+	case found.Status.Phase != corev1.ClaimBound:
+		cond.Reason = status.ConditionReason(found.Status.Phase)
+		cond.Message = fmt.Sprintf("the claim %s is %s", found.Name, found.Status.Phase)
+		if found.Status.Phase == corev1.ClaimPending {
+			r.Eventf(corev1.EventTypeWarning, resources.ReasonBackingStoragePending, "%s, %s", cond.Message, r.pendingReason(found))
+		}
+	case !usableAccessMode(found.Status.AccessModes):
+		cond.Reason = "InvalidAccessMode"
+		cond.Message = fmt.Sprintf("the claim %s cannot be written, it requires the access mode %s or %s", found.Name, corev1.ReadWriteOnce, corev1.ReadWriteMany)
+	default:
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = "Bound"
+		cond.Message = fmt.Sprintf("the claim %s is bound to the volume %s", found.Name, found.Spec.VolumeName)
+		if !r.IsOwned(found) {
+			cond.Message += ", the claim is provided by the user"
+		}
+
+		if capacity, ok := found.Status.Capacity[corev1.ResourceStorage]; ok {
+			st.Capacity = capacity.String()
+			r.capacity = &capacity
+		}
+		if r.IsOwned(found) {
+			size, resizeCond, err := r.resize(found)
+			if err != nil {
+				return err
+			}
+			if resizeCond.Reason == "Resizing" || resizeCond.Reason == "FileSystemResizePending" {
+				st.RequestedCapacity = size.String()
+			}
+			st.Conditions.SetCondition(resizeCond)
+		}
+		accessModes := make([]string, len(found.Status.AccessModes))
+		for i, am := range found.Status.AccessModes {
+			accessModes[i] = string(am)
+		}
+		st.AccessMode = strings.Join(accessModes, ",")
+	}
+
+	st.Conditions.SetCondition(cond)
+
+	return nil
+}
+
+// Capacity returns the capacity of the bound claim observed by Observe
+func (r *ResPersistentVolumeClaim) Capacity() (resource.Quantity, bool) {
+	if r.capacity == nil {
+		return resource.Quantity{}, false
+	}
+	return r.capacity.DeepCopy(), true
+}
+
+// pendingReason explains why the given claim is pending, from its StorageClass.
+// The provisioner of the StorageClass reports the details in the Events of the
+// claim
+func (r *ResPersistentVolumeClaim) pendingReason(found *corev1.PersistentVolumeClaim) string {
+	if found.Spec.StorageClassName == nil || len(*found.Spec.StorageClassName) == 0 {
+		return "it has no StorageClass and there is no default StorageClass to provision a volume, it waits for the administrator to create a matching PersistentVolume"
+	}
+	name := *found.Spec.StorageClassName
+
+	sc := &storagev1.StorageClass{}
+	err := r.Client.Get(context.TODO(), client.ObjectKey{Name: name}, sc)
+	if errors.IsNotFound(err) {
+		return fmt.Sprintf("the StorageClass %s does not exists", name)
+	}
+	if err == nil && sc.VolumeBindingMode != nil && *sc.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer {
+		return fmt.Sprintf("the StorageClass %s provisions the volume when the NFS provisioner Pod is scheduled, check the Events of the Pod", name)
+	}
+	return fmt.Sprintf("waiting for the StorageClass %s to provision the volume, check the Events of the claim", name)
+}
+
+// usableAccessMode returns true if the access modes allow the NFS Provisioner
+// to write into the volume
+func usableAccessMode(accessModes []corev1.PersistentVolumeAccessMode) bool {
+	for _, am := range accessModes {
+		if am == corev1.ReadWriteOnce || am == corev1.ReadWriteMany {
+			return true
+		}
+	}
+	return false
+}
+
+// newPersistentVolumeClaim returns the definition of this resource as should
+// exists, rendered from its manifest template. The name, namespace and owner
+// labels are set by the operator, the template cannot change them
+func (r *ResPersistentVolumeClaim) newPersistentVolumeClaim(manifests resources.Manifests) (*corev1.PersistentVolumeClaim, error) {
+	data := claimData{
+		TemplateData:          resources.NewTemplateData(r.Owner),
+		ClaimStorageClassName: r.Owner.Spec.BackingStorage.StorageClass,
+	}
+	if len(data.ClaimStorageClassName) == 0 {
+		data.ClaimStorageClassName = r.defaultStorageClass
+	}
+	// without storage size the claim is not created, it's provided by the user.
+	// An invalid size is rejected by the webhook and reported by the controller
+	if size, err := resource.ParseQuantity(r.Owner.Spec.BackingStorage.StorageSize); err == nil {
+		data.StorageSize = size.String()
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := manifests.Decode(manifestPersistentVolumeClaim, contentPersistentVolumeClaim, data, pvc); err != nil {
+		return nil, err
+	}
+	pvc.Name = resources.BackingStorageClaimName(r.Owner)
+	pvc.Namespace = r.Owner.Namespace
+	pvc.Labels = r.WithOwnerLabels(pvc.Labels)
+
+	return pvc, nil
+}
+
+func (r *ResPersistentVolumeClaim) getPersistentVolumeClaim() (*corev1.PersistentVolumeClaim, error) {
+	found := &corev1.PersistentVolumeClaim{}
+	objKey, err := client.ObjectKeyFromObject(r.Object)
+	if err != nil {
+		return nil, fmt.Errorf("fail to retreive the object key. %s", err)
+	}
+	// 	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: r.Object.Name, Namespace: r.Owner.Namespace}, found)
+	err = r.Client.Get(context.TODO(), objKey, found)
+	if err == nil {
+		return found, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,61 @@
+package pvc
+
+import (
+	"github.com/go-logr/logr"
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Resources implements the backend.Backend interface
+type Resources struct {
+	owner     *ibmcloudv1alpha1.Nfs
+	resources []resources.Reconcilable
+}
+
+// New creates a resources group for a backing storage provided by a
+// PersistentVolumeClaim of the given default storage class, unless other
+// storage class is set in the spec. A TemplateError is returned if the claim
+// manifest template is invalid
+func New(owner *ibmcloudv1alpha1.Nfs, defaultStorageClass string, manifests resources.Manifests, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) (*Resources, error) {
+	claim, err := PersistentVolumeClaim(owner, defaultStorageClass, manifests, client, scheme, recorder, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Resources{
+		owner:     owner,
+		resources: []resources.Reconcilable{claim},
+	}, nil
+}
+
+// Resources returns the group of reconcilable resources required to
+// have a NFS Provisioner
+func (r *Resources) Resources() []resources.Reconcilable {
+	return r.resources
+}
+
+// Reconcile creates the the Resources that does not exists and sets the Owner as an
+// owner reference on the Object
+func (r *Resources) Reconcile() (reconcile.Result, error) {
+	for _, resource := range r.resources {
+		result, err := resources.ReconcileResource(resource)
+		if err != nil {
+			return result, err
+		}
+	}
+	return reconcile.Result{}, nil
+}
+
+// VolumeSource returns the volume to export by the NFS Provisioner
+func (r *Resources) VolumeSource() corev1.VolumeSource {
+	return corev1.VolumeSource{
+		PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: resources.BackingStorageClaimName(r.owner),
+		},
+	}
+}
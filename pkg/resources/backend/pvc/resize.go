@@ -0,0 +1,94 @@
+package pvc
+
+import (
+	"context"
+	"fmt"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// resize returns the storage size to request on the existing claim and the
+// BackingStorageResized condition explaining the state of the resize. A larger
+// size is requested only if the storage class allows volume expansion, if it's
+// not allowed the current size is returned. A smaller size is never requested,
+// the current size is returned and the condition reports it. The size of the
+// latest automatic expansion is not a shrink
+func (r *ResPersistentVolumeClaim) resize(found *corev1.PersistentVolumeClaim) (resource.Quantity, status.Condition, error) {
+	current := found.Spec.Resources.Requests[corev1.ResourceStorage]
+	desired, ok := r.Object.Spec.Resources.Requests[corev1.ResourceStorage]
+	if !ok {
+		desired = current
+	}
+	if expanded, ok := r.expandedSize(); ok && expanded.Cmp(desired) > 0 {
+		desired = expanded
+	}
+	capacity := found.Status.Capacity[corev1.ResourceStorage]
+
+	cond := status.Condition{
+		Type:   ibmcloudv1alpha1.ConditionBackingStorageResized,
+		Status: corev1.ConditionFalse,
+	}
+
+	switch desired.Cmp(current) {
+	case -1:
+		cond.Reason = "ShrinkNotAllowed"
+		cond.Message = fmt.Sprintf("the claim %s cannot shrink from %s to %s, it keeps the size %s", found.Name, current.String(), desired.String(), current.String())
+		return current, cond, nil
+
+	case 1:
+		allowed, err := r.allowVolumeExpansion(found)
+		if err != nil {
+			return current, cond, err
+		}
+		if !allowed {
+			cond.Reason = "ExpansionNotAllowed"
+			cond.Message = fmt.Sprintf("the storage class of the claim %s does not allow volume expansion to %s", found.Name, desired.String())
+			return current, cond, nil
+		}
+		cond.Reason = "Resizing"
+		cond.Message = fmt.Sprintf("requested to resize the claim %s from %s to %s", found.Name, current.String(), desired.String())
+		return desired, cond, nil
+	}
+
+	// the requested size is the desired size, check if the resize is done
+	if found.Status.Phase != corev1.ClaimBound || capacity.Cmp(desired) >= 0 {
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = "Resized"
+		cond.Message = fmt.Sprintf("the claim %s has the requested size %s", found.Name, desired.String())
+		return desired, cond, nil
+	}
+
+	cond.Reason = "Resizing"
+	cond.Message = fmt.Sprintf("resizing the claim %s from %s to %s", found.Name, capacity.String(), desired.String())
+	for _, c := range found.Status.Conditions {
+		if c.Type == corev1.PersistentVolumeClaimFileSystemResizePending && c.Status == corev1.ConditionTrue {
+			cond.Reason = "FileSystemResizePending"
+			cond.Message = fmt.Sprintf("the volume of the claim %s is resized to %s, waiting for the file system resize on the node", found.Name, desired.String())
+		}
+	}
+
+	return desired, cond, nil
+}
+
+// allowVolumeExpansion returns true if the storage class of the claim allows
+// volume expansion
+func (r *ResPersistentVolumeClaim) allowVolumeExpansion(found *corev1.PersistentVolumeClaim) (bool, error) {
+	if found.Spec.StorageClassName == nil || len(*found.Spec.StorageClassName) == 0 {
+		return false, nil
+	}
+
+	sc := &storagev1.StorageClass{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: *found.Spec.StorageClassName}, sc)
+	exists, err := resources.Exists(err)
+	if !exists || err != nil {
+		return false, err
+	}
+
+	return sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion, nil
+}
@@ -0,0 +1,221 @@
+package pvc
+
+import (
+	"testing"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// newStorageClass returns a StorageClass that allows volume expansion or not
+func newStorageClass(name string, allowExpansion bool) *storagev1.StorageClass {
+	return &storagev1.StorageClass{
+		ObjectMeta:           metav1.ObjectMeta{Name: name},
+		Provisioner:          "example.com/block",
+		AllowVolumeExpansion: &allowExpansion,
+	}
+}
+
+// newClaim returns a claim of the StorageClass with the given requested size
+// and capacity, it's bound if it has capacity
+func newClaim(storageClass, requested, capacity string) *corev1.PersistentVolumeClaim {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "nfs-nfs-block", Namespace: "test"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClass,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(requested)},
+			},
+		},
+		Status: corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+	}
+	if len(capacity) != 0 {
+		pvc.Status.Phase = corev1.ClaimBound
+		pvc.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(capacity)}
+	}
+	return pvc
+}
+
+// newTestClaim returns the claim resource of the Nfs requesting the given size,
+// with a client that has the given objects
+func newTestClaim(t *testing.T, owner *ibmcloudv1alpha1.Nfs, size string, objs ...runtime.Object) *ResPersistentVolumeClaim {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return &ResPersistentVolumeClaim{
+		Object:   newClaim("block", size, ""),
+		Resource: resources.New(owner, fake.NewFakeClientWithScheme(scheme, objs...), scheme, nil, logf.Log),
+	}
+}
+
+func TestResize(t *testing.T) {
+	tests := []struct {
+		name           string
+		size           string
+		claim          *corev1.PersistentVolumeClaim
+		allowExpansion bool
+		conditions     []corev1.PersistentVolumeClaimCondition
+		autoExpanded   string
+		wantSize       string
+		wantStatus     corev1.ConditionStatus
+		wantReason     string
+	}{
+		{
+			name:       "same size",
+			size:       "10Gi",
+			claim:      newClaim("block", "10Gi", "10Gi"),
+			wantSize:   "10Gi",
+			wantStatus: corev1.ConditionTrue,
+			wantReason: "Resized",
+		},
+		{
+			name:       "pending claim",
+			size:       "10Gi",
+			claim:      newClaim("block", "10Gi", ""),
+			wantSize:   "10Gi",
+			wantStatus: corev1.ConditionTrue,
+			wantReason: "Resized",
+		},
+		{
+			name:           "expand",
+			size:           "20Gi",
+			claim:          newClaim("block", "10Gi", "10Gi"),
+			allowExpansion: true,
+			wantSize:       "20Gi",
+			wantStatus:     corev1.ConditionFalse,
+			wantReason:     "Resizing",
+		},
+		{
+			name:       "expansion not allowed",
+			size:       "20Gi",
+			claim:      newClaim("block", "10Gi", "10Gi"),
+			wantSize:   "10Gi",
+			wantStatus: corev1.ConditionFalse,
+			wantReason: "ExpansionNotAllowed",
+		},
+		{
+			name:           "resizing",
+			size:           "20Gi",
+			claim:          newClaim("block", "20Gi", "10Gi"),
+			allowExpansion: true,
+			wantSize:       "20Gi",
+			wantStatus:     corev1.ConditionFalse,
+			wantReason:     "Resizing",
+		},
+		{
+			name:           "file system resize pending",
+			size:           "20Gi",
+			claim:          newClaim("block", "20Gi", "10Gi"),
+			allowExpansion: true,
+			conditions: []corev1.PersistentVolumeClaimCondition{
+				{Type: corev1.PersistentVolumeClaimFileSystemResizePending, Status: corev1.ConditionTrue},
+			},
+			wantSize:   "20Gi",
+			wantStatus: corev1.ConditionFalse,
+			wantReason: "FileSystemResizePending",
+		},
+		{
+			name:       "resized",
+			size:       "20Gi",
+			claim:      newClaim("block", "20Gi", "20Gi"),
+			wantSize:   "20Gi",
+			wantStatus: corev1.ConditionTrue,
+			wantReason: "Resized",
+		},
+		{
+			name:       "shrink not allowed",
+			size:       "10Gi",
+			claim:      newClaim("block", "20Gi", "20Gi"),
+			wantSize:   "20Gi",
+			wantStatus: corev1.ConditionFalse,
+			wantReason: "ShrinkNotAllowed",
+		},
+		{
+			name:           "shrink while resizing",
+			size:           "10Gi",
+			claim:          newClaim("block", "20Gi", "15Gi"),
+			allowExpansion: true,
+			wantSize:       "20Gi",
+			wantStatus:     corev1.ConditionFalse,
+			wantReason:     "ShrinkNotAllowed",
+		},
+		{
+			name:           "auto expanded size is kept",
+			size:           "10Gi",
+			claim:          newClaim("block", "15Gi", "15Gi"),
+			allowExpansion: true,
+			autoExpanded:   "15Gi",
+			wantSize:       "15Gi",
+			wantStatus:     corev1.ConditionTrue,
+			wantReason:     "Resized",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner := &ibmcloudv1alpha1.Nfs{ObjectMeta: metav1.ObjectMeta{Name: "nfs", Namespace: "test"}}
+			if len(tt.autoExpanded) != 0 {
+				owner.Spec.BackingStorage.AutoExpand = &ibmcloudv1alpha1.AutoExpandSpec{TriggerPercent: 80, Step: "5Gi", MaxSize: "50Gi"}
+				owner.Status.AutoExpansion = &ibmcloudv1alpha1.AutoExpansionStatus{Count: 1, To: resource.MustParse(tt.autoExpanded)}
+			}
+			tt.claim.Status.Conditions = tt.conditions
+			r := newTestClaim(t, owner, tt.size, newStorageClass("block", tt.allowExpansion))
+
+			size, cond, err := r.resize(tt.claim)
+			if err != nil {
+				t.Fatalf("resize() error = %v", err)
+			}
+			if want := resource.MustParse(tt.wantSize); size.Cmp(want) != 0 {
+				t.Errorf("resize() size = %s, want %s", size.String(), want.String())
+			}
+			if cond.Type != ibmcloudv1alpha1.ConditionBackingStorageResized || cond.Status != tt.wantStatus || string(cond.Reason) != tt.wantReason {
+				t.Errorf("resize() condition = %s %s %s, want %s %s", cond.Type, cond.Status, cond.Reason, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestObserveRequestedCapacity(t *testing.T) {
+	owner := &ibmcloudv1alpha1.Nfs{ObjectMeta: metav1.ObjectMeta{Name: "nfs", Namespace: "test", UID: "nfs-uid"}}
+	tests := []struct {
+		name          string
+		claim         *corev1.PersistentVolumeClaim
+		wantCapacity  string
+		wantRequested string
+	}{
+		{
+			name:          "resizing",
+			claim:         newClaim("block", "20Gi", "10Gi"),
+			wantCapacity:  "10Gi",
+			wantRequested: "20Gi",
+		},
+		{
+			name:         "resized",
+			claim:        newClaim("block", "20Gi", "20Gi"),
+			wantCapacity: "20Gi",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.claim.OwnerReferences = []metav1.OwnerReference{{UID: owner.UID}}
+			tt.claim.Status.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+			r := newTestClaim(t, owner, "20Gi", tt.claim, newStorageClass("block", true))
+
+			st := &ibmcloudv1alpha1.NfsStatus{RequestedCapacity: "15Gi"}
+			if err := r.Observe(st); err != nil {
+				t.Fatalf("Observe() error = %v", err)
+			}
+			if st.Capacity != tt.wantCapacity || st.RequestedCapacity != tt.wantRequested {
+				t.Errorf("Observe() capacity = %q, requested = %q, want %q, %q", st.Capacity, st.RequestedCapacity, tt.wantCapacity, tt.wantRequested)
+			}
+		})
+	}
+}
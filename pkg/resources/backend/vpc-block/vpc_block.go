@@ -4,46 +4,20 @@ import (
 	"github.com/go-logr/logr"
 	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
 	"github.com/johandry/nfs-operator/pkg/resources"
+	"github.com/johandry/nfs-operator/pkg/resources/backend/pvc"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 const (
-	persistentVolumeClaimName = "nfs-block-custom"
+	storageClassName = "ibmc-vpc-block-general-purpose"
 )
 
-// Resources implements the resources.Group interface
-type Resources struct {
-	resources []resources.Reconcilable
-}
-
-// New creates a resources group for the NFS Provisioner
-func New(owner *ibmcloudv1alpha1.Nfs, client client.Client, scheme *runtime.Scheme, log logr.Logger) *Resources {
+// New creates a resources group for a backing storage provided by a IBM Cloud
+// VPC Block claim. The storage class is ibmc-vpc-block-general-purpose unless
+// other VPC Block storage class is set in the spec
+func New(owner *ibmcloudv1alpha1.Nfs, manifests resources.Manifests, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) (*pvc.Resources, error) {
 	log = log.WithName("vpc-block")
-	resources := []resources.Reconcilable{
-		PersistentVolumeClaim(owner, client, scheme, log),
-	}
-
-	return &Resources{
-		resources: resources,
-	}
-}
-
-// Resources returns the group of reconcilable resources required to
-// have a NFS Provisioner
-func (r *Resources) Resources() []resources.Reconcilable {
-	return r.resources
-}
-
-// Reconcile creates the the Resources that does not exists and sets the Owner as an
-// owner reference on the Object
-func (r *Resources) Reconcile() (reconcile.Result, error) {
-	for _, resource := range r.resources {
-		result, err := resource.Reconcile()
-		if err != nil {
-			return result, err
-		}
-	}
-	return reconcile.Result{}, nil
+	return pvc.New(owner, storageClassName, manifests, client, scheme, recorder, log)
 }
@@ -0,0 +1,63 @@
+package resources
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ConflictError is returned when a resource required by the Nfs already exists
+// but it belongs to someone else, so it cannot be created or updated
+type ConflictError struct {
+	Kind    string
+	Name    string
+	Message string
+}
+
+// NewConflictError creates a ConflictError for the given resource
+func NewConflictError(kind, name, format string, a ...interface{}) *ConflictError {
+	return &ConflictError{
+		Kind:    kind,
+		Name:    name,
+		Message: fmt.Sprintf(format, a...),
+	}
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict with the %s %s: %s", e.Kind, e.Name, e.Message)
+}
+
+// IsConflict returns true if the given error is, or wraps, a ConflictError
+func IsConflict(err error) bool {
+	var conflictErr *ConflictError
+	return errors.As(err, &conflictErr)
+}
+
+// TemplateError is returned when the manifest template of a resource cannot be
+// rendered or decoded, the error is in the template and not in the Nfs
+type TemplateError struct {
+	Name string
+	Err  error
+}
+
+// NewTemplateError creates a TemplateError for the manifest template with the
+// given name
+func NewTemplateError(name string, err error) *TemplateError {
+	return &TemplateError{
+		Name: name,
+		Err:  err,
+	}
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("invalid manifest template %s: %s", e.Name, e.Err)
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// IsTemplateError returns true if the given error is, or wraps, a TemplateError
+func IsTemplateError(err error) bool {
+	var templateErr *TemplateError
+	return errors.As(err, &templateErr)
+}
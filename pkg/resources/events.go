@@ -0,0 +1,124 @@
+package resources
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// Reasons of the Events emitted on the Nfs
+const (
+	// ReasonCreated is a resource created by the operator
+	ReasonCreated = "Created"
+	// ReasonUpdated is a resource changed by the operator
+	ReasonUpdated = "Updated"
+	// ReasonDeleted is a resource deleted by the operator
+	ReasonDeleted = "Deleted"
+	// ReasonConflict is a resource that is not created or updated because it
+	// belongs to someone else
+	ReasonConflict = "Conflict"
+	// ReasonFailed is a resource that failed to reconcile
+	ReasonFailed = "Failed"
+	// ReasonInvalidSpec is a Nfs with an invalid spec, it's not reconciled
+	ReasonInvalidSpec = "InvalidSpec"
+	// ReasonInvalidTemplate is a manifest template that cannot be rendered, the
+	// Nfs is not reconciled
+	ReasonInvalidTemplate = "InvalidTemplate"
+	// ReasonBackingStoragePending is a backing storage claim that is not bound
+	ReasonBackingStoragePending = "BackingStoragePending"
+	// ReasonStorageUsageHigh is an exported volume that reached the usage
+	// warning threshold
+	ReasonStorageUsageHigh = "StorageUsageHigh"
+	// ReasonAutoExpanded is a backing storage claim expanded by the autoExpand
+	// policy
+	ReasonAutoExpanded = "AutoExpanded"
+	// ReasonAutoExpandLimitReached is a backing storage claim that reached the
+	// usage to expand it but it has the max size of the autoExpand policy
+	ReasonAutoExpandLimitReached = "AutoExpandLimitReached"
+)
+
+// EventInterval is the minimum time between similar Events, with the same type,
+// reason and message, on the same object
+const EventInterval = 5 * time.Minute
+
+// Eventf emits an Event on the Owner. The resources created only to know the
+// types to watch have no recorder, they do not emit Events
+func (r Resource) Eventf(eventtype, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder == nil || r.Owner == nil {
+		return
+	}
+	r.Recorder.Eventf(r.Owner, eventtype, reason, messageFmt, args...)
+}
+
+var _ record.EventRecorder = &rateLimitedRecorder{}
+
+// rateLimitedRecorder drops the Events similar to other emitted in the last
+// interval. Every reconcile of a Nfs that is not ready or in conflict repeats
+// the same Events, the recorder aggregates them but it still sends a request to
+// the API server for each one
+type rateLimitedRecorder struct {
+	recorder record.EventRecorder
+	interval time.Duration
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// NewEventRecorder returns an EventRecorder that emits similar Events on the same
+// object at most once every EventInterval
+func NewEventRecorder(recorder record.EventRecorder) record.EventRecorder {
+	return &rateLimitedRecorder{
+		recorder: recorder,
+		interval: EventInterval,
+		last:     map[string]time.Time{},
+	}
+}
+
+// Event emits the Event unless a similar one was emitted in the last interval
+func (r *rateLimitedRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	if r.allow(object, eventtype, reason, message) {
+		r.recorder.Event(object, eventtype, reason, message)
+	}
+}
+
+// Eventf is just like Event, but with Sprintf for the message field
+func (r *rateLimitedRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+// AnnotatedEventf is just like Eventf, but with annotations attached
+func (r *rateLimitedRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	message := fmt.Sprintf(messageFmt, args...)
+	if r.allow(object, eventtype, reason, message) {
+		r.recorder.AnnotatedEventf(object, annotations, eventtype, reason, "%s", message)
+	}
+}
+
+// allow returns true if a similar Event was not emitted in the last interval,
+// and takes note of it. The notes older than the interval are forgotten
+func (r *rateLimitedRecorder) allow(object runtime.Object, eventtype, reason, message string) bool {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		// the recorder reports the error
+		return true
+	}
+	key := fmt.Sprintf("%s/%s/%s/%s", accessor.GetUID(), eventtype, reason, message)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for k, t := range r.last {
+		if now.Sub(t) >= r.interval {
+			delete(r.last, k)
+		}
+	}
+	if _, ok := r.last[key]; ok {
+		return false
+	}
+	r.last[key] = now
+	return true
+}
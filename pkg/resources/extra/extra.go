@@ -0,0 +1,156 @@
+package extra
+
+import (
+	"github.com/go-logr/logr"
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ resources.Observable = &Resources{}
+
+// Resources is the group of extra resources declared in the Nfs spec
+type Resources struct {
+	resources []resources.Reconcilable
+	// applied are the resources in the spec, removed are the resources applied
+	// before, recorded in the status, that are not in the spec anymore
+	applied []ibmcloudv1alpha1.ExtraResourceReference
+	removed []*resources.ResUnstructured
+}
+
+// New creates a resources group with the extra resources in the Nfs spec. Every
+// manifest is rendered with the given data and the mapper tells if it's a
+// namespaced or cluster scoped resource. It returns an Invalid API error if a
+// manifest cannot be rendered or decoded or its kind is not allowed by the
+// cluster admin, or the mapper error if the kind is unknown, it may be a CRD
+// that is not installed yet
+func New(owner *ibmcloudv1alpha1.Nfs, data resources.TemplateData, mapper meta.RESTMapper, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) (*Resources, error) {
+	log = log.WithName("extra")
+	errs := field.ErrorList{}
+	list := []resources.Reconcilable{}
+	applied := []ibmcloudv1alpha1.ExtraResourceReference{}
+
+	for i, raw := range owner.Spec.ExtraResources {
+		path := field.NewPath("spec", "extraResources").Index(i)
+
+		content, err := resources.Render(path.String(), raw.Raw, data)
+		if err != nil {
+			errs = append(errs, field.Invalid(path, string(raw.Raw), err.Error()))
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(content); err != nil {
+			errs = append(errs, field.Invalid(path, string(content), err.Error()))
+			continue
+		}
+		if len(obj.GetName()) == 0 {
+			errs = append(errs, field.Required(path.Child("metadata", "name"), ""))
+			continue
+		}
+
+		gvk := obj.GroupVersionKind()
+		if !ibmcloudv1alpha1.IsAllowedExtraKind(gvk.GroupKind()) {
+			errs = append(errs, ibmcloudv1alpha1.ExtraKindNotAllowed(path, gvk.GroupKind()))
+			continue
+		}
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return nil, err
+		}
+		namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+
+		res := resources.Unstructured(obj, namespaced, owner, client, scheme, recorder, log)
+		list = append(list, res)
+		applied = append(applied, reference(res.Object))
+	}
+
+	if len(errs) != 0 {
+		return nil, apierrors.NewInvalid(ibmcloudv1alpha1.SchemeGroupVersion.WithKind("Nfs").GroupKind(), owner.Name, errs)
+	}
+
+	removed := []*resources.ResUnstructured{}
+	for _, ref := range owner.Status.ExtraResources {
+		if contains(applied, ref) {
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(ref.APIVersion)
+		obj.SetKind(ref.Kind)
+		obj.SetName(ref.Name)
+		removed = append(removed, resources.Unstructured(obj, len(ref.Namespace) != 0, owner, client, scheme, recorder, log))
+	}
+
+	return &Resources{
+		resources: list,
+		applied:   applied,
+		removed:   removed,
+	}, nil
+}
+
+// Resources returns the group of reconcilable extra resources
+func (r *Resources) Resources() []resources.Reconcilable {
+	return r.resources
+}
+
+// Reconcile creates or updates the extra resources in the same order they are
+// in the Nfs spec
+func (r *Resources) Reconcile() (reconcile.Result, error) {
+	for _, resource := range r.resources {
+		result, err := resources.ReconcileResource(resource)
+		if err != nil {
+			return result, err
+		}
+	}
+	return reconcile.Result{}, nil
+}
+
+// Prune deletes the extra resources applied before that are not in the Nfs
+// spec anymore, if they are still owned by the Nfs
+func (r *Resources) Prune() error {
+	for len(r.removed) != 0 {
+		if err := r.removed[0].Prune(); err != nil {
+			return err
+		}
+		r.removed = r.removed[1:]
+	}
+	return nil
+}
+
+// Observe records the extra resources applied by the operator on the status,
+// with the removed ones not pruned yet so they are pruned later
+func (r *Resources) Observe(st *ibmcloudv1alpha1.NfsStatus) error {
+	st.ExtraResources = append([]ibmcloudv1alpha1.ExtraResourceReference(nil), r.applied...)
+	for _, res := range r.removed {
+		st.ExtraResources = append(st.ExtraResources, reference(res.Object))
+	}
+	return nil
+}
+
+// reference returns the reference to the given extra resource
+func reference(obj *unstructured.Unstructured) ibmcloudv1alpha1.ExtraResourceReference {
+	return ibmcloudv1alpha1.ExtraResourceReference{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+}
+
+// contains returns true if the list has the referenced resource in any version
+func contains(list []ibmcloudv1alpha1.ExtraResourceReference, ref ibmcloudv1alpha1.ExtraResourceReference) bool {
+	gk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind).GroupKind()
+	for _, e := range list {
+		if schema.FromAPIVersionAndKind(e.APIVersion, e.Kind).GroupKind() == gk && e.Namespace == ref.Namespace && e.Name == ref.Name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package extra
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// newMapper returns a RESTMapper that knows the namespaced ConfigMaps and the
+// cluster scoped ClusterRoles
+func newMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	mapper.Add(rbacv1.SchemeGroupVersion.WithKind("ClusterRole"), meta.RESTScopeRoot)
+	return mapper
+}
+
+// newOwner returns a Nfs with the given extra resources manifests, that applied
+// before the referenced resources
+func newOwner(manifests []string, applied ...ibmcloudv1alpha1.ExtraResourceReference) *ibmcloudv1alpha1.Nfs {
+	owner := &ibmcloudv1alpha1.Nfs{
+		ObjectMeta: metav1.ObjectMeta{Name: "nfs", Namespace: "test", UID: "uid"},
+		Status:     ibmcloudv1alpha1.NfsStatus{ExtraResources: applied},
+	}
+	for _, m := range manifests {
+		owner.Spec.ExtraResources = append(owner.Spec.ExtraResources, runtime.RawExtension{Raw: []byte(m)})
+	}
+	return owner
+}
+
+func TestNewKindNotAllowed(t *testing.T) {
+	defer func(kinds []string) { ibmcloudv1alpha1.AllowedExtraKinds = kinds }(ibmcloudv1alpha1.AllowedExtraKinds)
+	ibmcloudv1alpha1.AllowedExtraKinds = []string{"ConfigMap"}
+
+	owner := newOwner([]string{
+		`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings"}}`,
+		`{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"ClusterRole","metadata":{"name":"admin"}}`,
+	})
+	scheme := runtime.NewScheme()
+	_, err := New(owner, resources.NewTemplateData(owner), newMapper(), fake.NewFakeClientWithScheme(scheme), scheme, nil, logf.Log)
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("New() error = %v, want an Invalid error", err)
+	}
+	causes := err.(apierrors.APIStatus).Status().Details.Causes
+	if len(causes) != 1 || causes[0].Field != "spec.extraResources[1].kind" {
+		t.Errorf("New() causes = %v, want only spec.extraResources[1].kind", causes)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	defer func(kinds []string) { ibmcloudv1alpha1.AllowedExtraKinds = kinds }(ibmcloudv1alpha1.AllowedExtraKinds)
+	ibmcloudv1alpha1.AllowedExtraKinds = []string{"ConfigMap", "ClusterRole.rbac.authorization.k8s.io"}
+
+	ownerLabels := map[string]string{resources.LabelOwnerName: "nfs", resources.LabelOwnerNamespace: "test"}
+	settings := ibmcloudv1alpha1.ExtraResourceReference{APIVersion: "v1", Kind: "ConfigMap", Namespace: "test", Name: "settings"}
+	removedConfigMap := ibmcloudv1alpha1.ExtraResourceReference{APIVersion: "v1", Kind: "ConfigMap", Namespace: "test", Name: "removed"}
+	removedClusterRole := ibmcloudv1alpha1.ExtraResourceReference{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "removed"}
+	notOwned := ibmcloudv1alpha1.ExtraResourceReference{APIVersion: "v1", Kind: "ConfigMap", Namespace: "test", Name: "not-owned"}
+	oldVersion := ibmcloudv1alpha1.ExtraResourceReference{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRole", Name: "reader"}
+	reader := ibmcloudv1alpha1.ExtraResourceReference{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "reader"}
+
+	owner := newOwner([]string{
+		`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings"}}`,
+		`{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"ClusterRole","metadata":{"name":"reader"}}`,
+	}, settings, removedConfigMap, removedClusterRole, notOwned, oldVersion)
+	objs := []runtime.Object{
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "test", Labels: ownerLabels}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "removed", Namespace: "test", Labels: ownerLabels}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "not-owned", Namespace: "test"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "removed", Labels: ownerLabels}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "reader", Labels: ownerLabels}},
+	}
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewFakeClientWithScheme(scheme, objs...)
+
+	extras, err := New(owner, resources.NewTemplateData(owner), newMapper(), c, scheme, nil, logf.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st := &ibmcloudv1alpha1.NfsStatus{}
+	if err := extras.Observe(st); err != nil {
+		t.Fatal(err)
+	}
+	want := []ibmcloudv1alpha1.ExtraResourceReference{settings, reader, removedConfigMap, removedClusterRole, notOwned}
+	if !reflect.DeepEqual(st.ExtraResources, want) {
+		t.Errorf("Observe() before Prune() = %v, want %v", st.ExtraResources, want)
+	}
+
+	if err := extras.Prune(); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if err := extras.Observe(st); err != nil {
+		t.Fatal(err)
+	}
+	want = []ibmcloudv1alpha1.ExtraResourceReference{settings, reader}
+	if !reflect.DeepEqual(st.ExtraResources, want) {
+		t.Errorf("Observe() after Prune() = %v, want %v", st.ExtraResources, want)
+	}
+
+	exists := []struct {
+		obj  runtime.Object
+		key  client.ObjectKey
+		want bool
+	}{
+		{&corev1.ConfigMap{}, client.ObjectKey{Namespace: "test", Name: "settings"}, true},
+		{&corev1.ConfigMap{}, client.ObjectKey{Namespace: "test", Name: "removed"}, false},
+		{&corev1.ConfigMap{}, client.ObjectKey{Namespace: "test", Name: "not-owned"}, true},
+		{&rbacv1.ClusterRole{}, client.ObjectKey{Name: "removed"}, false},
+		{&rbacv1.ClusterRole{}, client.ObjectKey{Name: "reader"}, true},
+	}
+	for _, e := range exists {
+		err := c.Get(context.TODO(), e.key, e.obj)
+		if got, err := resources.Exists(err); err != nil || got != e.want {
+			t.Errorf("%T %s exists = %v (%v), want %v", e.obj, e.key, got, err, e.want)
+		}
+	}
+}
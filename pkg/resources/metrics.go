@@ -0,0 +1,75 @@
+package resources
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Results of the reconciliation of a resource, the label result of the metric
+// nfs_operator_resource_reconcile_total
+const (
+	// ResultCreated is a resource created by the apply
+	ResultCreated = "created"
+	// ResultUpdated is a resource changed by the apply
+	ResultUpdated = "updated"
+	// ResultSkipped is a resource not changed, it's up to date or it's not
+	// managed by the operator, like a claim provided by the user
+	ResultSkipped = "skipped"
+	// ResultError is a resource that failed to reconcile
+	ResultError = "error"
+)
+
+var (
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "nfs_operator_resource_reconcile_total",
+		Help: "Total number of reconciliations of the resources of every Nfs per kind and result: created, updated, skipped or error",
+	}, []string{"kind", "result"})
+
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "nfs_operator_resource_reconcile_duration_seconds",
+		Help:    "Duration in seconds of the reconciliation of the resources of every Nfs per kind",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"kind"})
+)
+
+func init() {
+	// served by the controller-runtime metrics endpoint of the manager
+	metrics.Registry.MustRegister(reconcileTotal, reconcileDuration)
+}
+
+// RecordResult counts the result of the reconciliation of a resource of the
+// given kind
+func RecordResult(kind, result string) {
+	reconcileTotal.WithLabelValues(kind, result).Inc()
+}
+
+// ReconcileResource reconciles the given resource recording the duration, and
+// the error if it fails. The other results are recorded by the resource
+func ReconcileResource(res Reconcilable) (reconcile.Result, error) {
+	kind := kindOf(res.Type())
+	start := time.Now()
+	result, err := res.Reconcile()
+	reconcileDuration.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+	if err != nil {
+		RecordResult(kind, ResultError)
+	}
+	return result, err
+}
+
+// kindOf returns the kind of the object, from its GVK for the unstructured
+// objects or from its Go type for the typed objects, named after their kind
+func kindOf(obj runtime.Object) string {
+	if kind := obj.GetObjectKind().GroupVersionKind().Kind; len(kind) != 0 {
+		return kind
+	}
+	t := reflect.TypeOf(obj)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
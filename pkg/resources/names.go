@@ -0,0 +1,87 @@
+package resources
+
+import (
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+)
+
+// LegacyNamesAnnotation marks a Nfs created before the names of its resources
+// were taken from the spec and the instance. Its resources keep the names they
+// were created with, otherwise they would be created again empty and the old
+// ones orphaned
+const LegacyNamesAnnotation = "ibmcloud.ibm.com/legacy-names"
+
+// Names of the resources of a Nfs with the LegacyNamesAnnotation, the spec was
+// ignored when they were created
+const (
+	LegacyClaimName        = "nfs-block-custom"
+	LegacyAppName          = "nfs-provisioner"
+	LegacyStorageClassName = "ibmcloud-nfs"
+	LegacyProvisionerName  = "ibmcloud/nfs"
+)
+
+// HasLegacyNames returns true if the resources of the given Nfs have the names
+// used before they were taken from the spec and the instance
+func HasLegacyNames(owner *ibmcloudv1alpha1.Nfs) bool {
+	return owner.Annotations[LegacyNamesAnnotation] == "true"
+}
+
+// BackingStorageClaimName returns the name of the PersistentVolumeClaim used as
+// backing storage by the given Nfs. If it's not in the spec, the name is
+// prefixed with the Nfs name so multiple instances do not collide
+func BackingStorageClaimName(owner *ibmcloudv1alpha1.Nfs) string {
+	if HasLegacyNames(owner) {
+		return LegacyClaimName
+	}
+	if name := owner.Spec.BackingStorage.PvcName; len(name) != 0 {
+		return name
+	}
+	return owner.Name + "-nfs-block"
+}
+
+// AppName returns the name of the namespaced resources of the NFS Provisioner,
+// also the value of the label "app" of its Pods. It's prefixed with the Nfs
+// name so multiple instances do not collide
+func AppName(owner *ibmcloudv1alpha1.Nfs) string {
+	if HasLegacyNames(owner) {
+		return LegacyAppName
+	}
+	return owner.Name + ibmcloudv1alpha1.AppNameSuffix
+}
+
+// ExportVolumeName is the name of the volume of the NFS Provisioner Pod with
+// the backing storage, mounted in /export
+const ExportVolumeName = "export-volume"
+
+// StorageClassName returns the StorageClass name from the spec, if not set
+// it's the Nfs namespace and name as the StorageClass is cluster scoped
+func StorageClassName(owner *ibmcloudv1alpha1.Nfs) string {
+	if HasLegacyNames(owner) {
+		return LegacyStorageClassName
+	}
+	if len(owner.Spec.StorageClass) != 0 {
+		return owner.Spec.StorageClass
+	}
+	return owner.Namespace + "-" + owner.Name
+}
+
+// StorageClassNames returns the names of every StorageClass served by the NFS
+// provisioner of the given Nfs, its StorageClass and the StorageClass profiles
+func StorageClassNames(owner *ibmcloudv1alpha1.Nfs) []string {
+	names := []string{StorageClassName(owner)}
+	for _, p := range owner.Spec.StorageClassProfiles {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// ProvisionerName returns the provisioner name from the spec, if not set it's
+// unique for the Nfs namespace and name
+func ProvisionerName(owner *ibmcloudv1alpha1.Nfs) string {
+	if HasLegacyNames(owner) {
+		return LegacyProvisionerName
+	}
+	if len(owner.Spec.ProvisionerAPI) != 0 {
+		return owner.Spec.ProvisionerAPI
+	}
+	return ibmcloudv1alpha1.SchemeGroupVersion.Group + "/" + owner.Namespace + "-" + owner.Name
+}
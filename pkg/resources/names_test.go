@@ -0,0 +1,73 @@
+package resources
+
+import (
+	"testing"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNames(t *testing.T) {
+	legacy := map[string]string{LegacyNamesAnnotation: "true"}
+	tests := []struct {
+		name            string
+		annotations     map[string]string
+		spec            ibmcloudv1alpha1.NfsSpec
+		wantClaim       string
+		wantApp         string
+		wantClass       string
+		wantProvisioner string
+	}{
+		{
+			name:            "defaults",
+			wantClaim:       "nfs-nfs-block",
+			wantApp:         "nfs-nfs-provisioner",
+			wantClass:       "test-nfs",
+			wantProvisioner: "ibmcloud.ibm.com/test-nfs",
+		},
+		{
+			name: "from the spec",
+			spec: ibmcloudv1alpha1.NfsSpec{
+				StorageClass:   "shared",
+				ProvisionerAPI: "example.com/nfs",
+				BackingStorage: ibmcloudv1alpha1.BackingStorageSpec{PvcName: "data"},
+			},
+			wantClaim:       "data",
+			wantApp:         "nfs-nfs-provisioner",
+			wantClass:       "shared",
+			wantProvisioner: "example.com/nfs",
+		},
+		{
+			name:        "legacy ignores the spec",
+			annotations: legacy,
+			spec: ibmcloudv1alpha1.NfsSpec{
+				StorageClass:   "example-nfs",
+				ProvisionerAPI: "example.com/nfs",
+			},
+			wantClaim:       LegacyClaimName,
+			wantApp:         LegacyAppName,
+			wantClass:       LegacyStorageClassName,
+			wantProvisioner: LegacyProvisionerName,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner := &ibmcloudv1alpha1.Nfs{
+				ObjectMeta: metav1.ObjectMeta{Name: "nfs", Namespace: "test", Annotations: tt.annotations},
+				Spec:       tt.spec,
+			}
+			if got := BackingStorageClaimName(owner); got != tt.wantClaim {
+				t.Errorf("BackingStorageClaimName() = %q, want %q", got, tt.wantClaim)
+			}
+			if got := AppName(owner); got != tt.wantApp {
+				t.Errorf("AppName() = %q, want %q", got, tt.wantApp)
+			}
+			if got := StorageClassName(owner); got != tt.wantClass {
+				t.Errorf("StorageClassName() = %q, want %q", got, tt.wantClass)
+			}
+			if got := ProvisionerName(owner); got != tt.wantProvisioner {
+				t.Errorf("ProvisionerName() = %q, want %q", got, tt.wantProvisioner)
+			}
+		})
+	}
+}
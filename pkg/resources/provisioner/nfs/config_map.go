@@ -0,0 +1,322 @@
+package nfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"github.com/johandry/nfs-operator/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ resources.Reconcilable = &ResConfigMap{}
+
+// ResConfigMap is the resource ConfigMap with the NFS server (Ganesha)
+// configuration
+type ResConfigMap struct {
+	Object *corev1.ConfigMap
+	resources.Resource
+}
+
+// manifestConfigMap is the name of the ConfigMap manifest template
+const manifestConfigMap = "config-map.yaml"
+
+// contentConfigMap is rendered with the configMapData. The NFS provisioner
+// keeps the Ganesha configuration in /export/vfs.conf, adding an EXPORT block
+// with read-write access for any client for every volume. The file vfs.conf is
+// the initial configuration, used only if the backing storage doesn't have one.
+// The options of an EXPORT block override the EXPORT_DEFAULTS, so exports.sh
+// sets the options of export-options.conf in every EXPORT block before the NFS
+// server starts, and removes the blocks of the deleted volumes as the
+// provisioner cannot find them to remove them
+var contentConfigMap = []byte(`
+kind: ConfigMap
+apiVersion: v1
+metadata:
+  name: {{ .AppName }}-ganesha
+  namespace: {{ .Namespace }}
+data:
+  vfs.conf: |
+    EXPORT
+    {
+      Export_Id = 0;
+      Path = /nonexistent;
+      Pseudo = /nonexistent;
+      Access_Type = None;
+      FSAL {
+        Name = VFS;
+      }
+    }
+
+    NFS_Core_Param
+    {
+      MNT_Port = 20048;
+      fsid_device = true;
+    }
+
+    NFSV4
+    {
+      Grace_Period = 90;
+    }
+  export-options.conf: |
+    Access_Type = {{ if .Restricted }}None{{ else }}{{ .AccessType }}{{ end }};
+    Squash = {{ .Squash }};
+    Protocols = {{ .Protocols }};
+    {{- with .AnonymousUID }}
+    Anonymous_Uid = {{ . }};
+    {{- end }}
+    {{- with .AnonymousGID }}
+    Anonymous_Gid = {{ . }};
+    {{- end }}
+    {{- if .Restricted }}
+    CLIENT {
+      Clients = {{ .Clients }};
+      Access_Type = {{ .AccessType }};
+    }
+    {{- end }}
+  exports.sh: |
+    # usage: exports.sh <ganesha config> <directory of this ConfigMap>
+    set -e
+    config="$1"
+    dir="$2"
+    [ -f "$config" ] || cp "$dir/vfs.conf" "$config"
+
+    tab=$(printf '\t')
+    nl='
+    '
+    options=""
+    while IFS= read -r o || [ -n "$o" ]; do
+      options="$options$tab$o$nl"
+    done < "$dir/export-options.conf"
+
+    tmp="$config.tmp"
+    : > "$tmp"
+    depth=0
+    inexport=0
+    while IFS= read -r line || [ -n "$line" ]; do
+      t="${line#"${line%%[![:space:]]*}"}"
+      if [ "$inexport" -eq 0 ]; then
+        case "$t" in
+          EXPORT|EXPORT\ \{|EXPORT\{)
+            inexport=1 block="" path="" placeholder=0 client=0 ;;
+          '%include "/etc/nfs-operator/export-defaults.conf"')
+            continue ;;
+          *)
+            printf '%s\n' "$line" >> "$tmp"
+            continue ;;
+        esac
+      fi
+
+      level=$depth
+      case "$t" in *"{"*) depth=$((depth + 1)) ;; esac
+      case "$t" in *"}"*) depth=$((depth - 1)) ;; esac
+
+      if [ "$placeholder" -eq 0 ] && [ "$level" -eq 1 ]; then
+        case "$t" in
+          "Export_Id = 0;") placeholder=1 ;;
+          "Path = "*) path="${t#Path = }" path="${path%;}" ;;
+          CLIENT*) client=1 ;;
+          Access_Type*|Squash*|Protocols*|Anonymous_Uid*|Anonymous_Gid*) continue ;;
+          FSAL*) block="$block$options" ;;
+        esac
+      fi
+      if [ "$client" -eq 1 ]; then
+        case "$t" in *"}"*) [ "$depth" -gt 1 ] || client=0 ;; esac
+        continue
+      fi
+      block="$block$line$nl"
+
+      if [ "$depth" -eq 0 ] && [ "$level" -gt 0 ]; then
+        inexport=0
+        if [ "$placeholder" -eq 1 ] || [ -d "$path" ]; then
+          printf '%s' "$block" >> "$tmp"
+        else
+          echo "Removing the EXPORT block of the deleted volume $path"
+        fi
+      fi
+    done < "$config"
+    mv "$tmp" "$config"
+`)
+
+// configMapData is the data to render the ConfigMap manifest template, the
+// export options of the Nfs in the Ganesha syntax
+type configMapData struct {
+	resources.TemplateData
+	AccessType string
+	Squash     string
+	// Clients is the list of allowed clients, * for any client
+	Clients string
+	// Restricted is true if not every client is allowed, the access of the
+	// other clients is denied
+	Restricted   bool
+	Protocols    string
+	AnonymousUID string
+	AnonymousGID string
+}
+
+// ConfigMap creates a ConfigMap from its manifest template
+func ConfigMap(owner *ibmcloudv1alpha1.Nfs, manifests resources.Manifests, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) (*ResConfigMap, error) {
+	res := &ResConfigMap{}
+	res.Resource = resources.New(owner, client, scheme, recorder, log)
+	obj, err := res.newConfigMap(manifests)
+	if err != nil {
+		return nil, err
+	}
+	res.Object = obj
+	apiVersion, kind := resources.GVK(res.Object, res.Scheme)
+	res.Log = res.Log.WithValues("Resource.Name", res.Object.GetName(), "Resource.Namespace", res.Object.GetNamespace(), "Resource.APIVersion", apiVersion, "Resource.Kind", kind)
+
+	return res, nil
+}
+
+// Get returns the Object from the cluster
+func (r *ResConfigMap) Get() (runtime.Object, error) {
+	return r.getConfigMap()
+}
+
+// Type returns an empty object of the type of this resource, to watch it
+func (r *ResConfigMap) Type() runtime.Object {
+	return &corev1.ConfigMap{}
+}
+
+// Apply creates or updates the Object with server-side apply
+func (r *ResConfigMap) Apply() error {
+	return r.ServerSideApply(r.Object)
+}
+
+// Reconcile creates the Object if it does not exists and sets the Owner as an
+// owner reference on the Object
+func (r *ResConfigMap) Reconcile() (reconcile.Result, error) {
+	if r.Owner == nil {
+		return reconcile.Result{}, fmt.Errorf("the resource %s/%s does not have an owner", r.Object.Namespace, r.Object.Name)
+	}
+	r.Log.Info("Reconciling " + r.Object.Name + " resource")
+	if err := controllerutil.SetControllerReference(r.Owner, r.Object, r.Scheme); err != nil {
+		r.Log.Error(err, "Failed to set controller reference to resource")
+		return reconcile.Result{}, err
+	}
+	err := r.Apply()
+
+	return reconcile.Result{}, err
+}
+
+// Hash returns a hash of the configuration, it changes when the content of the
+// ConfigMap changes
+func (r *ResConfigMap) Hash() string {
+	data, _ := json.Marshal(r.Object.Data)
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// ExportsHash returns a hash of the configuration and of the volumes exported
+// with it. The NFS provisioner exports a new volume with its defaults and the
+// export options are set in its EXPORT block only when the NFS server starts,
+// so if the Nfs has other options the hash changes when a volume is provisioned
+// or deleted. Otherwise it's the Hash of the configuration
+func (r *ResConfigMap) ExportsHash() (string, error) {
+	if newConfigMapData(r.Owner).defaults() {
+		return r.Hash(), nil
+	}
+
+	volumes, err := resources.ProvisionedVolumes(r.Client, r.Owner)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(volumes))
+	for _, pv := range volumes {
+		names = append(names, pv.Name)
+	}
+	sort.Strings(names)
+	data, _ := json.Marshal(struct {
+		Data    map[string]string `json:"data"`
+		Volumes []string          `json:"volumes"`
+	}{r.Object.Data, names})
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// newConfigMap returns the definition of this resource as should exists,
+// rendered from its manifest template. The name and namespace are set by the
+// operator, the template cannot change them
+func (r *ResConfigMap) newConfigMap(manifests resources.Manifests) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{}
+	if err := manifests.Decode(manifestConfigMap, contentConfigMap, newConfigMapData(r.Owner), cm); err != nil {
+		return nil, err
+	}
+	cm.Name = configMapName(r.Owner)
+	cm.Namespace = r.Owner.Namespace
+
+	return cm, nil
+}
+
+// newConfigMapData returns the export options of the Nfs in the Ganesha syntax.
+// The options not set are the defaults of the NFS provisioner: read-write
+// access, no squash, any client and the NFS versions 3 and 4
+func newConfigMapData(owner *ibmcloudv1alpha1.Nfs) configMapData {
+	exports := owner.Spec.Exports
+	data := configMapData{
+		TemplateData: resources.NewTemplateData(owner),
+		AccessType:   "RW",
+		Squash:       "no_root_squash",
+		Clients:      "*",
+		Protocols:    "3, 4",
+	}
+
+	if exports.Access == ibmcloudv1alpha1.ExportReadOnly {
+		data.AccessType = "RO"
+	}
+	switch exports.Squash {
+	case ibmcloudv1alpha1.ExportSquashRoot:
+		data.Squash = "root_squash"
+	case ibmcloudv1alpha1.ExportSquashAll:
+		data.Squash = "all_squash"
+	}
+	if len(exports.AllowedClients) != 0 {
+		data.Clients = strings.Join(exports.AllowedClients, ", ")
+		data.Restricted = true
+	}
+	if len(exports.NFSVersions) != 0 {
+		versions := make([]string, 0, len(exports.NFSVersions))
+		for _, v := range exports.NFSVersions {
+			versions = append(versions, strconv.Itoa(int(v)))
+		}
+		data.Protocols = strings.Join(versions, ", ")
+	}
+	if exports.AnonymousUID != nil {
+		data.AnonymousUID = strconv.FormatInt(*exports.AnonymousUID, 10)
+	}
+	if exports.AnonymousGID != nil {
+		data.AnonymousGID = strconv.FormatInt(*exports.AnonymousGID, 10)
+	}
+
+	return data
+}
+
+// defaults returns true if the export options are the ones the NFS provisioner
+// sets on the EXPORT block of a new volume
+func (d configMapData) defaults() bool {
+	return d.AccessType == "RW" && d.Squash == "no_root_squash" && !d.Restricted && d.Protocols == "3, 4" &&
+		len(d.AnonymousUID) == 0 && len(d.AnonymousGID) == 0
+}
+
+func (r *ResConfigMap) getConfigMap() (*corev1.ConfigMap, error) {
+	found := &corev1.ConfigMap{}
+	objKey, err := client.ObjectKeyFromObject(r.Object)
+	if err != nil {
+		return nil, fmt.Errorf("fail to retreive the object key. %s", err)
+	}
+	err = r.Client.Get(context.TODO(), objKey, found)
+	if err == nil {
+		return found, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,250 @@
+package nfs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// newTestConfigMap returns the ConfigMap rendered with the given export options
+func newTestConfigMap(t *testing.T, exports ibmcloudv1alpha1.ExportsSpec) *ResConfigMap {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	owner := &ibmcloudv1alpha1.Nfs{
+		ObjectMeta: metav1.ObjectMeta{Name: "nfs", Namespace: "test"},
+		Spec:       ibmcloudv1alpha1.NfsSpec{Exports: exports},
+	}
+	cm, err := ConfigMap(owner, nil, nil, scheme, nil, logf.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cm
+}
+
+func TestExportOptions(t *testing.T) {
+	uid, gid := int64(65534), int64(65533)
+	tests := []struct {
+		name    string
+		exports ibmcloudv1alpha1.ExportsSpec
+		want    string
+	}{
+		{
+			name: "defaults",
+			want: "Access_Type = RW;\nSquash = no_root_squash;\nProtocols = 3, 4;\n",
+		},
+		{
+			name:    "read only",
+			exports: ibmcloudv1alpha1.ExportsSpec{Access: ibmcloudv1alpha1.ExportReadOnly},
+			want:    "Access_Type = RO;\nSquash = no_root_squash;\nProtocols = 3, 4;\n",
+		},
+		{
+			name: "root squash with anonymous ids",
+			exports: ibmcloudv1alpha1.ExportsSpec{
+				Squash:       ibmcloudv1alpha1.ExportSquashRoot,
+				AnonymousUID: &uid,
+				AnonymousGID: &gid,
+			},
+			want: "Access_Type = RW;\nSquash = root_squash;\nProtocols = 3, 4;\nAnonymous_Uid = 65534;\nAnonymous_Gid = 65533;\n",
+		},
+		{
+			name:    "all squash and NFS version 4",
+			exports: ibmcloudv1alpha1.ExportsSpec{Squash: ibmcloudv1alpha1.ExportSquashAll, NFSVersions: []ibmcloudv1alpha1.NFSVersion{4}},
+			want:    "Access_Type = RW;\nSquash = all_squash;\nProtocols = 4;\n",
+		},
+		{
+			name: "allowed clients",
+			exports: ibmcloudv1alpha1.ExportsSpec{
+				Access:         ibmcloudv1alpha1.ExportReadOnly,
+				AllowedClients: []string{"10.240.0.0/16", "10.10.10.10"},
+			},
+			want: "Access_Type = None;\nSquash = no_root_squash;\nProtocols = 3, 4;\nCLIENT {\n  Clients = 10.240.0.0/16, 10.10.10.10;\n  Access_Type = RO;\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := newTestConfigMap(t, tt.exports)
+			if got := cm.Object.Data["export-options.conf"]; got != tt.want {
+				t.Errorf("export-options.conf =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// provisionerExport is an EXPORT block as the NFS provisioner writes it
+func provisionerExport(id, path string) string {
+	return "\nEXPORT\n{\n" +
+		"\tExport_Id = " + id + ";\n" +
+		"\tPath = " + path + ";\n" +
+		"\tPseudo = " + path + ";\n" +
+		"\tAccess_Type = RW;\n" +
+		"\tSquash = no_root_squash;\n" +
+		"\tSecType = sys;\n" +
+		"\tFilesystem_id = " + id + "." + id + ";\n" +
+		"\tFSAL {\n\t\tName = VFS;\n\t}\n}\n"
+}
+
+func TestExportsScript(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("the script requires sh")
+	}
+
+	tmp, err := ioutil.TempDir("", "exports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	exportDir := filepath.Join(tmp, "export")
+	configDir := filepath.Join(tmp, "config")
+	volume := filepath.Join(exportDir, "pvc-1")
+	for _, dir := range []string{volume, configDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deleted := filepath.Join(exportDir, "pvc-2")
+
+	cm := newTestConfigMap(t, ibmcloudv1alpha1.ExportsSpec{
+		Access:         ibmcloudv1alpha1.ExportReadOnly,
+		Squash:         ibmcloudv1alpha1.ExportSquashAll,
+		AllowedClients: []string{"10.240.0.0/16"},
+	})
+	for name, content := range cm.Object.Data {
+		if err := ioutil.WriteFile(filepath.Join(configDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the configuration of a provisioner started before, with the include of the
+	// previous versions of the operator
+	config := filepath.Join(exportDir, "vfs.conf")
+	initial := cm.Object.Data["vfs.conf"] +
+		"\n%include \"/etc/nfs-operator/export-defaults.conf\"\n" +
+		provisionerExport("1", volume) +
+		provisionerExport("2", deleted)
+	if err := ioutil.WriteFile(config, []byte(initial), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := cm.Object.Data["vfs.conf"] + "\n" +
+		"\nEXPORT\n{\n" +
+		"\tExport_Id = 1;\n" +
+		"\tPath = " + volume + ";\n" +
+		"\tPseudo = " + volume + ";\n" +
+		"\tSecType = sys;\n" +
+		"\tFilesystem_id = 1.1;\n" +
+		"\tAccess_Type = None;\n" +
+		"\tSquash = all_squash;\n" +
+		"\tProtocols = 3, 4;\n" +
+		"\tCLIENT {\n" +
+		"\t  Clients = 10.240.0.0/16;\n" +
+		"\t  Access_Type = RO;\n" +
+		"\t}\n" +
+		"\tFSAL {\n\t\tName = VFS;\n\t}\n}\n" +
+		"\n"
+
+	// it's run on every start of the Pod, the second run does not change it
+	for run := 1; run <= 2; run++ {
+		out, err := exec.Command("sh", filepath.Join(configDir, "exports.sh"), config, configDir).CombinedOutput()
+		if err != nil {
+			t.Fatalf("run %d: exports.sh failed: %v\n%s", run, err, out)
+		}
+		got, err := ioutil.ReadFile(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("run %d: vfs.conf =\n%s\nwant\n%s", run, got, want)
+		}
+	}
+}
+
+func TestExportsHash(t *testing.T) {
+	newVolume := func(name string) runtime.Object {
+		return &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       corev1.PersistentVolumeSpec{StorageClassName: "test-nfs"},
+		}
+	}
+	tests := []struct {
+		name        string
+		exports     ibmcloudv1alpha1.ExportsSpec
+		volumes     []runtime.Object
+		wantChanged bool
+	}{
+		{
+			name:    "defaults without volumes",
+			exports: ibmcloudv1alpha1.ExportsSpec{Access: ibmcloudv1alpha1.ExportReadWrite},
+		},
+		{
+			name:    "defaults with volumes",
+			exports: ibmcloudv1alpha1.ExportsSpec{Access: ibmcloudv1alpha1.ExportReadWrite},
+			volumes: []runtime.Object{newVolume("pvc-1")},
+		},
+		{
+			name:        "options without volumes",
+			exports:     ibmcloudv1alpha1.ExportsSpec{Squash: ibmcloudv1alpha1.ExportSquashRoot},
+			wantChanged: true,
+		},
+		{
+			name:        "options with volumes",
+			exports:     ibmcloudv1alpha1.ExportsSpec{AllowedClients: []string{"10.240.0.0/16"}},
+			volumes:     []runtime.Object{newVolume("pvc-2"), newVolume("pvc-1")},
+			wantChanged: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := clientgoscheme.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			c := fake.NewFakeClientWithScheme(scheme, tt.volumes...)
+			owner := &ibmcloudv1alpha1.Nfs{
+				ObjectMeta: metav1.ObjectMeta{Name: "nfs", Namespace: "test"},
+				Spec:       ibmcloudv1alpha1.NfsSpec{Exports: tt.exports},
+			}
+			cm, err := ConfigMap(owner, nil, c, scheme, nil, logf.Log)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			before, err := cm.ExportsHash()
+			if err != nil {
+				t.Fatalf("ExportsHash() error = %v", err)
+			}
+			if err := c.Create(context.TODO(), newVolume("pvc-new")); err != nil {
+				t.Fatal(err)
+			}
+			after, err := cm.ExportsHash()
+			if err != nil {
+				t.Fatalf("ExportsHash() error = %v", err)
+			}
+			if changed := before != after; changed != tt.wantChanged {
+				t.Errorf("ExportsHash() changed with a new volume = %v, want %v", changed, tt.wantChanged)
+			}
+			if !tt.wantChanged && after != cm.Hash() {
+				t.Errorf("ExportsHash() = %s, want the configuration Hash() %s", after, cm.Hash())
+			}
+
+			if err := c.Delete(context.TODO(), newVolume("pvc-new")); err != nil {
+				t.Fatal(err)
+			}
+			if again, _ := cm.ExportsHash(); again != before {
+				t.Errorf("ExportsHash() after deleting the volume = %s, want %s", again, before)
+			}
+		})
+	}
+}
@@ -3,48 +3,113 @@ package nfs
 import (
 	"context"
 	"fmt"
+	"strconv"
 
 	"github.com/go-logr/logr"
 	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
 	"github.com/johandry/nfs-operator/pkg/resources"
+	"github.com/operator-framework/operator-sdk/pkg/status"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 var _ resources.Reconcilable = &ResDeployment{}
+var _ resources.Observable = &ResDeployment{}
 
 // ResDeployment is the resource Deployment
 type ResDeployment struct {
-	Object *appsv1.Deployment
+	Object       *appsv1.Deployment
+	exportVolume corev1.VolumeSource
+	config       *ResConfigMap
 	resources.Resource
 }
 
+// manifestDeployment is the name of the Deployment manifest template
+const manifestDeployment = "deployment.yaml"
+
+// containerName is the name of the NFS provisioner container, the settings of
+// the podTemplate are applied to it
+const containerName = "nfs-provisioner"
+
+// nfsPort is the port of the NFS server, probed by the TCP probes
+const nfsPort = 2049
+
+// nfsProgram is the RPC program number of the NFS service, probed by the RPC
+// probes through rpcbind
+const nfsProgram = "100003"
+
+// defaultLivenessProbe and defaultReadinessProbe are the timing of the probes
+// not set in the Nfs. The liveness probe tolerates a longer outage, restarting
+// the NFS server starts a new grace period for every client
+var (
+	defaultLivenessProbe = corev1.Probe{
+		InitialDelaySeconds: 60,
+		PeriodSeconds:       20,
+		TimeoutSeconds:      10,
+		FailureThreshold:    6,
+		SuccessThreshold:    1,
+	}
+	defaultReadinessProbe = corev1.Probe{
+		InitialDelaySeconds: 10,
+		PeriodSeconds:       10,
+		TimeoutSeconds:      5,
+		FailureThreshold:    3,
+		SuccessThreshold:    1,
+	}
+)
+
+// annotationConfigHash is the annotation of the Pod with the hash of the NFS
+// server configuration, a new Pod is rolled out when the configuration changes
+// or, with export options, when the set of exported volumes changes
+const annotationConfigHash = "ibmcloud.ibm.com/ganesha-config-hash"
+
+// contentDeployment is rendered with the deploymentData, the export volume has
+// to be mounted in /export. The init container sets the export options of the
+// ConfigMap in every EXPORT block of the Ganesha configuration kept in the
+// export volume
 var contentDeployment = []byte(`
 kind: Deployment
 apiVersion: apps/v1
 metadata:
-  name: nfs-provisioner
+  name: {{ .AppName }}
+  namespace: {{ .Namespace }}
 spec:
   selector:
     matchLabels:
-      app: nfs-provisioner
+      app: {{ .AppName }}
   replicas: 1
   strategy:
     type: Recreate
   template:
     metadata:
       labels:
-        app: nfs-provisioner
+        app: {{ .AppName }}
     spec:
-      serviceAccount: nfs-provisioner
+      serviceAccountName: {{ .AppName }}
+      initContainers:
+        - name: ganesha-config
+          image: {{ .Image }}
+          imagePullPolicy: IfNotPresent
+          command:
+            - /bin/sh
+            - /etc/nfs-operator/exports.sh
+            - /export/vfs.conf
+            - /etc/nfs-operator
+          volumeMounts:
+            - name: export-volume
+              mountPath: /export
+            - name: ganesha-config
+              mountPath: /etc/nfs-operator
+              readOnly: true
       containers:
         - name: nfs-provisioner
-          image: quay.io/kubernetes_incubator/nfs-provisioner:latest
+          image: {{ .Image }}
           ports:
             - name: nfs
               containerPort: 2049
@@ -82,37 +147,66 @@ spec:
                 - DAC_READ_SEARCH
                 - SYS_RESOURCE
           args:
-            - "-provisioner=ibmcloud/nfs"
+            - "-provisioner={{ .ProvisionerName }}"
+            {{- if .RootSquash }}
+            - "-root-squash"
+            {{- end }}
           env:
             - name: POD_IP
               valueFrom:
                 fieldRef:
                   fieldPath: status.podIP
             - name: SERVICE_NAME
-              value: nfs-provisioner
+              value: {{ .AppName }}
             - name: POD_NAMESPACE
               valueFrom:
                 fieldRef:
                   fieldPath: metadata.namespace
-          imagePullPolicy: "IfNotPresent"
+          imagePullPolicy: IfNotPresent
           volumeMounts:
             - name: export-volume
               mountPath: /export
+            - name: ganesha-config
+              mountPath: /etc/nfs-operator
+              readOnly: true
       volumes:
-        - name: export-volume
-          persistentVolumeClaim:
-            claimName: nfs-block-custom
+        - {{ .ExportVolume | toJson }}
+        - name: ganesha-config
+          configMap:
+            name: {{ .ConfigMapName }}
 `)
 
-// Deployment creates a Deployment
-func Deployment(owner *ibmcloudv1alpha1.Nfs, client client.Client, scheme *runtime.Scheme, log logr.Logger) *ResDeployment {
-	res := &ResDeployment{}
-	res.Resource = resources.New(owner, client, scheme, log)
-	res.Object = res.newDeployment()
+// deploymentData is the data to render the Deployment manifest template
+type deploymentData struct {
+	resources.TemplateData
+	Image string
+	// ExportVolume is the volume named export-volume provided by the backing
+	// storage
+	ExportVolume corev1.Volume
+	// ConfigMapName is the ConfigMap with the NFS server configuration
+	ConfigMapName string
+	// RootSquash is true if the provisioner has to squash the root user on the
+	// exports it creates
+	RootSquash bool
+}
+
+// Deployment creates a Deployment from its manifest template, exporting the
+// given volume with the NFS server configuration of the given ConfigMap
+func Deployment(owner *ibmcloudv1alpha1.Nfs, exportVolume corev1.VolumeSource, config *ResConfigMap, manifests resources.Manifests, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) (*ResDeployment, error) {
+	res := &ResDeployment{
+		exportVolume: exportVolume,
+		config:       config,
+	}
+	res.Resource = resources.New(owner, client, scheme, recorder, log)
+	obj, err := res.newDeployment(manifests)
+	if err != nil {
+		return nil, err
+	}
+	res.Object = obj
 	apiVersion, kind := resources.GVK(res.Object, res.Scheme)
 	res.Log = res.Log.WithValues("Resource.Name", res.Object.GetName(), "Resource.Namespace", res.Object.GetNamespace(), "Resource.APIVersion", apiVersion, "Resource.Kind", kind)
 
-	return res
+	return res, nil
 }
 
 // Get returns the Object from the cluster
@@ -120,22 +214,14 @@ func (r *ResDeployment) Get() (runtime.Object, error) {
 	return r.getDeployment()
 }
 
-// Apply creates the Object if it does not exists
-func (r *ResDeployment) Apply() error {
-	_, err := r.getDeployment()
-	exists, err := resources.Exists(err)
-	if exists {
-		r.Log.Info("Skip reconcile: Resource already exists")
-		return nil
-	}
-	if err != nil {
-		r.Log.Error(err, "Failed to reconcile the resource")
-		return err
-	}
+// Type returns an empty object of the type of this resource, to watch it
+func (r *ResDeployment) Type() runtime.Object {
+	return &appsv1.Deployment{}
+}
 
-	// if not exists and no error, then create
-	r.Log.Info("Created a new resource")
-	return r.Client.Create(context.TODO(), r.Object)
+// Apply creates or updates the Object with server-side apply
+func (r *ResDeployment) Apply() error {
+	return r.ServerSideApply(r.Object)
 }
 
 // Reconcile creates the Object if it does not exists and sets the Owner as an
@@ -149,154 +235,290 @@ func (r *ResDeployment) Reconcile() (reconcile.Result, error) {
 		r.Log.Error(err, "Failed to set controller reference to resource")
 		return reconcile.Result{}, err
 	}
-	err := r.Apply()
+	// the Pod is rolled out to set the export options on the new volumes
+	hash, err := r.config.ExportsHash()
+	if err != nil {
+		r.Log.Error(err, "Failed to list the exported volumes")
+		return reconcile.Result{}, err
+	}
+	r.Object.Spec.Template.Annotations[annotationConfigHash] = hash
+	err = r.Apply()
 
 	return reconcile.Result{}, err
 }
 
-func (r *ResDeployment) newDeployment() *appsv1.Deployment {
+// Observe sets the ProvisionerReady condition on the given status. While the
+// Deployment is rolling out, i.e. after an image change, the condition is False
+// until the new Pod is available. Then the image of the running Pod is set on
+// the status
+func (r *ResDeployment) Observe(st *ibmcloudv1alpha1.NfsStatus) error {
+	found, err := r.getDeployment()
+	exists, err := resources.Exists(err)
+	if err != nil {
+		return err
+	}
+
+	cond := status.Condition{
+		Type:   ibmcloudv1alpha1.ConditionProvisionerReady,
+		Status: corev1.ConditionFalse,
+	}
+
+	// the manifest template may not set the replicas, the default is 1
 	replicas := int32(1)
+	if r.Object.Spec.Replicas != nil {
+		replicas = *r.Object.Spec.Replicas
+	}
+
+	switch {
+	case !exists:
+		cond.Reason = "NotFound"
+		cond.Message = fmt.Sprintf("the deployment %s does not exists", r.Object.Name)
+	case rolloutFailed(found):
+		cond.Reason = "RolloutFailed"
+		cond.Message = fmt.Sprintf("the deployment %s failed to roll out the image %s", found.Name, containerImage(&r.Object.Spec.Template.Spec))
+	case !r.rolledOut(found, replicas):
+		cond.Reason = "RollingOut"
+		cond.Message = fmt.Sprintf("the deployment %s is rolling out the image %s, %d of %d replicas updated", found.Name, containerImage(&r.Object.Spec.Template.Spec), found.Status.UpdatedReplicas, replicas)
+	case found.Status.AvailableReplicas < replicas:
+		cond.Reason = "Unavailable"
+		cond.Message = fmt.Sprintf("the deployment %s has %d of %d replicas available", found.Name, found.Status.AvailableReplicas, replicas)
+	default:
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = "Available"
+		cond.Message = fmt.Sprintf("the deployment %s is available", found.Name)
+		if err := r.observeImage(found, st); err != nil {
+			return err
+		}
+	}
+
+	st.Conditions.SetCondition(cond)
+
+	return nil
+}
+
+// rolledOut returns true if the Deployment runs the desired image and the
+// Deployment controller replaced every old Pod. The found Deployment may come
+// from a cache that does not have the latest applied image yet
+func (r *ResDeployment) rolledOut(found *appsv1.Deployment, replicas int32) bool {
+	if containerImage(&found.Spec.Template.Spec) != containerImage(&r.Object.Spec.Template.Spec) {
+		return false
+	}
+	return found.Status.ObservedGeneration >= found.Generation &&
+		found.Status.UpdatedReplicas >= replicas &&
+		found.Status.Replicas <= found.Status.UpdatedReplicas
+}
+
+// rolloutFailed returns true if the Deployment controller reports the rollout
+// exceeded its progress deadline, i.e. the new image cannot be pulled or the
+// new Pod never becomes ready
+func rolloutFailed(found *appsv1.Deployment) bool {
+	for _, c := range found.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Status == corev1.ConditionFalse && c.Reason == "ProgressDeadlineExceeded" {
+			return true
+		}
+	}
+	return false
+}
+
+// observeImage sets on the status the image of a ready NFS provisioner Pod of
+// the Deployment, with the image ID reported by the kubelet. The status is not
+// changed if there isn't a ready Pod with the desired image
+func (r *ResDeployment) observeImage(found *appsv1.Deployment, st *ibmcloudv1alpha1.NfsStatus) error {
+	pods := &corev1.PodList{}
+	if err := r.Client.List(context.TODO(), pods, client.InNamespace(found.Namespace), client.MatchingLabels(found.Spec.Selector.MatchLabels)); err != nil {
+		return err
+	}
 
-	return &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      appName,
-			Namespace: r.Owner.Namespace,
+	image := containerImage(&found.Spec.Template.Spec)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil || !podReady(pod) {
+			continue
+		}
+		container := provisionerContainer(&pod.Spec)
+		if container == nil || container.Image != image {
+			continue
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.Name == container.Name {
+				st.ProvisionerImage = image
+				st.ProvisionerImageID = cs.ImageID
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// podReady returns true if the Pod has the condition Ready
+func podReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// newDeployment returns the definition of this resource as should exists,
+// rendered from its manifest template. The name, namespace, strategy, probes
+// and the configuration hash are set by the operator, the template cannot
+// change them, and the pull secrets and podTemplate settings of the Nfs are
+// applied over the template
+func (r *ResDeployment) newDeployment(manifests resources.Manifests) (*appsv1.Deployment, error) {
+	squash := r.Owner.Spec.Exports.Squash
+	data := deploymentData{
+		TemplateData: resources.NewTemplateData(r.Owner),
+		Image:        image(r.Owner),
+		ExportVolume: corev1.Volume{
+			Name:         resources.ExportVolumeName,
+			VolumeSource: r.exportVolume,
 		},
-		Spec: appsv1.DeploymentSpec{
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app": appName,
-				},
-			},
-			Replicas: &replicas,
-			Strategy: appsv1.DeploymentStrategy{
-				Type: appsv1.RecreateDeploymentStrategyType,
-			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app": appName,
-					},
-				},
-				Spec: corev1.PodSpec{
-					ServiceAccountName: appName,
-					Containers: []corev1.Container{
-						{
-							Name:  appName,
-							Image: imageName,
-							Ports: []corev1.ContainerPort{
-								{
-									Name:          "nfs",
-									ContainerPort: 2049,
-								},
-								{
-									Name:          "nfs-udp",
-									ContainerPort: 2049,
-									Protocol:      corev1.ProtocolUDP,
-								},
-								{
-									Name:          "nlockmgr",
-									ContainerPort: 32803,
-								},
-								{
-									Name:          "nlockmgr-udp",
-									ContainerPort: 32803,
-									Protocol:      corev1.ProtocolUDP,
-								},
-								{
-									Name:          "mountd",
-									ContainerPort: 20048,
-								},
-								{
-									Name:          "mountd-udp",
-									ContainerPort: 20048,
-									Protocol:      corev1.ProtocolUDP,
-								},
-								{
-									Name:          "rquotad",
-									ContainerPort: 875,
-								},
-								{
-									Name:          "rquotad-udp",
-									ContainerPort: 875,
-									Protocol:      corev1.ProtocolUDP,
-								},
-								{
-									Name:          "rpcbind",
-									ContainerPort: 111,
-								},
-								{
-									Name:          "rpcbind-udp",
-									ContainerPort: 111,
-									Protocol:      corev1.ProtocolUDP,
-								},
-								{
-									Name:          "statd",
-									ContainerPort: 662,
-								},
-								{
-									Name:          "statd-udp",
-									ContainerPort: 662,
-									Protocol:      corev1.ProtocolUDP,
-								},
-							},
-							SecurityContext: &corev1.SecurityContext{
-								Capabilities: &corev1.Capabilities{
-									Add: []corev1.Capability{
-										"DAC_READ_SEARCH",
-										"SYS_RESOURCE",
-									},
-								},
-							},
-							Args: []string{
-								"-provisioner=ibmcloud/nfs",
-							},
-							Env: []corev1.EnvVar{
-								{
-									Name: "POD_IP",
-									ValueFrom: &corev1.EnvVarSource{
-										FieldRef: &corev1.ObjectFieldSelector{
-											FieldPath: "status.podIP",
-										},
-									},
-								},
-								{
-									Name:  "SERVICE_NAME",
-									Value: appName,
-								},
-								{
-									Name: "POD_NAMESPACE",
-									ValueFrom: &corev1.EnvVarSource{
-										FieldRef: &corev1.ObjectFieldSelector{
-											FieldPath: "metadata.namespace",
-										},
-									},
-								},
-							},
-							ImagePullPolicy: corev1.PullIfNotPresent,
-							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name:      "export-volume",
-									MountPath: "/export",
-								},
-							},
-						},
-					},
-					Volumes: []corev1.Volume{
-						{
-							Name: "export-volume",
-							VolumeSource: corev1.VolumeSource{
-								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									// TODO: Change the ClaimName for the user provided PVC
-									ClaimName: "nfs-block-custom",
-								},
-							},
-						},
-					},
-				},
-			},
+		ConfigMapName: r.config.Object.Name,
+		RootSquash:    squash == ibmcloudv1alpha1.ExportSquashRoot || squash == ibmcloudv1alpha1.ExportSquashAll,
+	}
+
+	deploy := &appsv1.Deployment{}
+	if err := manifests.Decode(manifestDeployment, contentDeployment, data, deploy); err != nil {
+		return nil, err
+	}
+	deploy.Name = resources.AppName(r.Owner)
+	deploy.Namespace = r.Owner.Namespace
+	// the old Pod has to release the backing volume, that may be ReadWriteOnce,
+	// before the new Pod starts
+	deploy.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+	deploy.Spec.Template.Spec.ImagePullSecrets = append(deploy.Spec.Template.Spec.ImagePullSecrets, r.Owner.Spec.Provisioner.ImagePullSecrets...)
+	r.applyPodTemplate(deploy)
+	deploy.Spec.Template.Annotations = mergeMap(deploy.Spec.Template.Annotations, map[string]string{
+		annotationConfigHash: r.config.Hash(),
+	})
+
+	return deploy, nil
+}
+
+// applyPodTemplate sets the podTemplate settings of the Nfs on the Deployment.
+// The annotations, node selector, tolerations and env are added to the ones in
+// the template, the affinity, priority class and resources replace them. The
+// probes of the NFS server replace the ones in the template
+func (r *ResDeployment) applyPodTemplate(deploy *appsv1.Deployment) {
+	pt := r.Owner.Spec.Provisioner.PodTemplate.DeepCopy()
+	pod := &deploy.Spec.Template
+
+	pod.Annotations = mergeMap(pod.Annotations, pt.Annotations)
+	pod.Spec.NodeSelector = mergeMap(pod.Spec.NodeSelector, pt.NodeSelector)
+	pod.Spec.Tolerations = append(pod.Spec.Tolerations, pt.Tolerations...)
+	if pt.Affinity != nil {
+		pod.Spec.Affinity = pt.Affinity
+	}
+	if len(pt.PriorityClassName) != 0 {
+		pod.Spec.PriorityClassName = pt.PriorityClassName
+	}
+
+	container := provisionerContainer(&pod.Spec)
+	if container == nil {
+		r.Log.Info("Skip podTemplate: the Deployment template does not have containers")
+		return
+	}
+	if len(pt.Resources.Limits) != 0 || len(pt.Resources.Requests) != 0 {
+		container.Resources = pt.Resources
+	}
+	for _, env := range pt.Env {
+		container.Env = setEnv(container.Env, env)
+	}
+
+	probes := r.Owner.Spec.Provisioner.Probes
+	container.LivenessProbe = r.newProbe(probes.Liveness, defaultLivenessProbe)
+	container.ReadinessProbe = r.newProbe(probes.Readiness, defaultReadinessProbe)
+}
+
+// newProbe returns the probe of the NFS server with the given settings, or nil
+// if it's disabled. The settings not set are taken from the given defaults
+func (r *ResDeployment) newProbe(spec ibmcloudv1alpha1.ProbeSpec, defaults corev1.Probe) *corev1.Probe {
+	if spec.Disabled {
+		return nil
+	}
+
+	probe := defaults.DeepCopy()
+	if spec.InitialDelaySeconds != nil {
+		probe.InitialDelaySeconds = *spec.InitialDelaySeconds
+	}
+	if spec.PeriodSeconds != nil {
+		probe.PeriodSeconds = *spec.PeriodSeconds
+	}
+	if spec.TimeoutSeconds != nil {
+		probe.TimeoutSeconds = *spec.TimeoutSeconds
+	}
+	if spec.FailureThreshold != nil {
+		probe.FailureThreshold = *spec.FailureThreshold
+	}
+
+	if spec.Type == ibmcloudv1alpha1.ProbeTCP {
+		probe.Handler = corev1.Handler{
+			TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(nfsPort)},
+		}
+		return probe
+	}
+
+	// rpcinfo asks rpcbind for the NFS service and calls its null procedure, so
+	// it fails if any of them does not answer
+	version := 4
+	if servesOnlyNFSv3(r.Owner) {
+		version = 3
+	}
+	probe.Handler = corev1.Handler{
+		Exec: &corev1.ExecAction{
+			Command: []string{"rpcinfo", "-t", "127.0.0.1", nfsProgram, strconv.Itoa(version)},
 		},
 	}
+	return probe
+}
+
+// provisionerContainer returns the NFS provisioner container of the Pod, or the
+// first container if a custom template names it different
+func provisionerContainer(spec *corev1.PodSpec) *corev1.Container {
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == containerName {
+			return &spec.Containers[i]
+		}
+	}
+	if len(spec.Containers) == 0 {
+		return nil
+	}
+	return &spec.Containers[0]
+}
+
+// containerImage returns the image of the NFS provisioner container of the Pod
+func containerImage(spec *corev1.PodSpec) string {
+	if container := provisionerContainer(spec); container != nil {
+		return container.Image
+	}
+	return ""
+}
+
+// setEnv replaces the variable with the same name or appends it to the list
+func setEnv(list []corev1.EnvVar, env corev1.EnvVar) []corev1.EnvVar {
+	for i := range list {
+		if list[i].Name == env.Name {
+			list[i] = env
+			return list
+		}
+	}
+	return append(list, env)
+}
+
+// mergeMap returns the dst map with the keys of src, src wins on conflicts
+func mergeMap(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
 }
 
 func (r *ResDeployment) getDeployment() (*appsv1.Deployment, error) {
@@ -4,42 +4,115 @@ import (
 	"github.com/go-logr/logr"
 	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
 	"github.com/johandry/nfs-operator/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-const (
-	appName                   = "nfs-provisioner"
-	imageName                 = "quay.io/kubernetes_incubator/nfs-provisioner:latest"
-	storageClassName          = "ibmcloud-nfs"
-	provisionerName           = "ibmcloud/nfs"
-	persistentVolumeClaimName = "nfs"
-)
+// DefaultImage is the NFS provisioner image used when the Nfs does not set one.
+// It's a fixed tag so every cluster runs the same provisioner, set with the
+// operator flag --provisioner-image
+var DefaultImage = "quay.io/kubernetes_incubator/nfs-provisioner:v2.3.0"
+
+// image returns the NFS provisioner image of the Nfs or the DefaultImage
+func image(owner *ibmcloudv1alpha1.Nfs) string {
+	if len(owner.Spec.Provisioner.Image) != 0 {
+		return owner.Spec.Provisioner.Image
+	}
+	return DefaultImage
+}
+
+// servesOnlyNFSv3 returns true if the exports of the Nfs serve only the NFS
+// version 3, the clients and probes cannot use the version 4
+func servesOnlyNFSv3(owner *ibmcloudv1alpha1.Nfs) bool {
+	v := owner.Spec.Exports.NFSVersions
+	return len(v) == 1 && v[0] == 3
+}
+
+// leaderLockingName returns the name of the Role and RoleBinding used by the
+// NFS Provisioner for the leader election
+func leaderLockingName(owner *ibmcloudv1alpha1.Nfs) string {
+	return "leader-locking-" + resources.AppName(owner)
+}
+
+// clusterRoleName returns the name of the ClusterRole and ClusterRoleBinding of
+// the NFS Provisioner, they are cluster scoped so it includes the Nfs namespace
+func clusterRoleName(owner *ibmcloudv1alpha1.Nfs) string {
+	return owner.Namespace + "-" + resources.AppName(owner) + "-runner"
+}
+
+// configMapName returns the name of the ConfigMap with the NFS server
+// configuration
+func configMapName(owner *ibmcloudv1alpha1.Nfs) string {
+	return resources.AppName(owner) + "-ganesha"
+}
 
 // Resources implements the resources.Group interface
 type Resources struct {
 	resources []resources.Reconcilable
 }
 
-// New creates a resources group for the NFS Provisioner
-func New(owner *ibmcloudv1alpha1.Nfs, client client.Client, scheme *runtime.Scheme, log logr.Logger) *Resources {
+// New creates a resources group for the NFS Provisioner exporting the given
+// volume, provided by the backing storage. The resources are rendered from
+// their manifest templates, a TemplateError is returned if any is invalid
+func New(owner *ibmcloudv1alpha1.Nfs, exportVolume corev1.VolumeSource, manifests resources.Manifests, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) (*Resources, error) {
 	log = log.WithName("nfs-provisioner")
-	resources := []resources.Reconcilable{
+	// the Deployment rolls the Pod when the configuration changes
+	config, err := ConfigMap(owner, manifests, client, scheme, recorder, log)
+	if err != nil {
+		return nil, err
+	}
+	constructors := []func() (resources.Reconcilable, error){
+		// StorageClass goes first, if it conflicts with other Nfs nothing else is created
+		func() (resources.Reconcilable, error) {
+			return StorageClass(owner, manifests, client, scheme, recorder, log)
+		},
+	}
+	for _, profile := range owner.Spec.StorageClassProfiles {
+		profile := profile
+		constructors = append(constructors, func() (resources.Reconcilable, error) {
+			return StorageClassProfile(owner, profile, manifests, client, scheme, recorder, log)
+		})
+	}
+	constructors = append(constructors,
 		// Deployment
-		Service(owner, client, scheme, log),
-		Deployment(owner, client, scheme, log),
+		func() (resources.Reconcilable, error) {
+			return Service(owner, manifests, client, scheme, recorder, log)
+		},
+		func() (resources.Reconcilable, error) { return config, nil },
+		func() (resources.Reconcilable, error) {
+			return Deployment(owner, exportVolume, config, manifests, client, scheme, recorder, log)
+		},
 		// RBAC
-		ServiceAccount(owner, client, scheme, log),
-		Role(owner, client, scheme, log),
-		RoleBinding(owner, client, scheme, log),
-		// StorageClass
-		StorageClass(owner, client, scheme, log),
+		func() (resources.Reconcilable, error) {
+			return ServiceAccount(owner, manifests, client, scheme, recorder, log)
+		},
+		func() (resources.Reconcilable, error) {
+			return ClusterRole(owner, manifests, client, scheme, recorder, log)
+		},
+		func() (resources.Reconcilable, error) {
+			return ClusterRoleBinding(owner, manifests, client, scheme, recorder, log)
+		},
+		func() (resources.Reconcilable, error) { return Role(owner, manifests, client, scheme, recorder, log) },
+		func() (resources.Reconcilable, error) {
+			return RoleBinding(owner, manifests, client, scheme, recorder, log)
+		},
+	)
+
+	list := make([]resources.Reconcilable, 0, len(constructors))
+	for _, newResource := range constructors {
+		res, err := newResource()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, res)
 	}
 
 	return &Resources{
-		resources: resources,
-	}
+		resources: list,
+	}, nil
 }
 
 // Resources returns the group of reconcilable resources required to
@@ -52,7 +125,7 @@ func (r *Resources) Resources() []resources.Reconcilable {
 // owner reference on the Object
 func (r *Resources) Reconcile() (reconcile.Result, error) {
 	for _, resource := range r.resources {
-		result, err := resource.Reconcile()
+		result, err := resources.ReconcileResource(resource)
 		if err != nil {
 			return result, err
 		}
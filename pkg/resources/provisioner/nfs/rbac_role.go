@@ -8,8 +8,8 @@ import (
 	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
 	"github.com/johandry/nfs-operator/pkg/resources"
 	rbacv1 "k8s.io/api/rbac/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -23,26 +23,38 @@ type ResRole struct {
 	resources.Resource
 }
 
+// manifestRole is the name of the Role manifest template
+const manifestRole = "role.yaml"
+
 var contentRole = []byte(`
 kind: Role
 apiVersion: rbac.authorization.k8s.io/v1
 metadata:
-  name: leader-locking-nfs-provisioner
+  name: leader-locking-{{ .AppName }}
+  namespace: {{ .Namespace }}
 rules:
   - apiGroups: [""]
     resources: ["endpoints"]
     verbs: ["get", "list", "watch", "create", "update", "patch"]
+  - apiGroups: [""]
+    resources: ["services"]
+    resourceNames: ["{{ .AppName }}"]
+    verbs: ["get"]
 `)
 
-// Role creates a Role
-func Role(owner *ibmcloudv1alpha1.Nfs, client client.Client, scheme *runtime.Scheme, log logr.Logger) *ResRole {
+// Role creates a Role from its manifest template
+func Role(owner *ibmcloudv1alpha1.Nfs, manifests resources.Manifests, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) (*ResRole, error) {
 	res := &ResRole{}
-	res.Resource = resources.New(owner, client, scheme, log)
-	res.Object = res.newRole()
+	res.Resource = resources.New(owner, client, scheme, recorder, log)
+	obj, err := res.newRole(manifests)
+	if err != nil {
+		return nil, err
+	}
+	res.Object = obj
 	apiVersion, kind := resources.GVK(res.Object, res.Scheme)
 	res.Log = res.Log.WithValues("Resource.Name", res.Object.GetName(), "Resource.Namespace", res.Object.GetNamespace(), "Resource.APIVersion", apiVersion, "Resource.Kind", kind)
 
-	return res
+	return res, nil
 }
 
 // Get returns the Object from the cluster
@@ -50,22 +62,14 @@ func (r *ResRole) Get() (runtime.Object, error) {
 	return r.getRole()
 }
 
-// Apply creates the Object if it does not exists
-func (r *ResRole) Apply() error {
-	_, err := r.getRole()
-	exists, err := resources.Exists(err)
-	if exists {
-		r.Log.Info("Skip reconcile: Resource already exists")
-		return nil
-	}
-	if err != nil {
-		r.Log.Error(err, "Failed to reconcile the resource")
-		return err
-	}
+// Type returns an empty object of the type of this resource, to watch it
+func (r *ResRole) Type() runtime.Object {
+	return &rbacv1.Role{}
+}
 
-	// if not exists and no error, then create
-	r.Log.Info("Created a new resource")
-	return r.Client.Create(context.TODO(), r.Object)
+// Apply creates or updates the Object with server-side apply
+func (r *ResRole) Apply() error {
+	return r.ServerSideApply(r.Object)
 }
 
 // Reconcile creates the Object if it does not exists and sets the Owner as an
@@ -84,21 +88,18 @@ func (r *ResRole) Reconcile() (reconcile.Result, error) {
 	return reconcile.Result{}, err
 }
 
-// newRole returns the definition of this resource as should exists
-func (r *ResRole) newRole() *rbacv1.Role {
-	return &rbacv1.Role{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "leader-locking-" + appName,
-			Namespace: r.Owner.Namespace,
-		},
-		Rules: []rbacv1.PolicyRule{
-			{
-				APIGroups: []string{""},
-				Resources: []string{"endpoints"},
-				Verbs:     []string{"get", "list", "watch", "create", "update", "patch"},
-			},
-		},
+// newRole returns the definition of this resource as should exists, rendered
+// from its manifest template. The name and namespace are set by the operator,
+// the template cannot change them
+func (r *ResRole) newRole(manifests resources.Manifests) (*rbacv1.Role, error) {
+	role := &rbacv1.Role{}
+	if err := manifests.Decode(manifestRole, contentRole, resources.NewTemplateData(r.Owner), role); err != nil {
+		return nil, err
 	}
+	role.Name = leaderLockingName(r.Owner)
+	role.Namespace = r.Owner.Namespace
+
+	return role, nil
 }
 
 func (r *ResRole) getRole() (*rbacv1.Role, error) {
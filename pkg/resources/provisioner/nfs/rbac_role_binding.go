@@ -8,8 +8,8 @@ import (
 	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
 	"github.com/johandry/nfs-operator/pkg/resources"
 	rbacv1 "k8s.io/api/rbac/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -23,31 +23,38 @@ type ResRoleBinding struct {
 	resources.Resource
 }
 
+// manifestRoleBinding is the name of the RoleBinding manifest template
+const manifestRoleBinding = "role-binding.yaml"
+
 var contentRoleBinding = []byte(`
 kind: RoleBinding
 apiVersion: rbac.authorization.k8s.io/v1
 metadata:
-  name: leader-locking-nfs-provisioner
+  name: leader-locking-{{ .AppName }}
+  namespace: {{ .Namespace }}
 subjects:
   - kind: ServiceAccount
-    name: nfs-provisioner
-    # replace with namespace where provisioner is deployed
-    namespace: default
+    name: {{ .AppName }}
+    namespace: {{ .Namespace }}
 roleRef:
   kind: Role
-  name: leader-locking-nfs-provisioner
+  name: leader-locking-{{ .AppName }}
   apiGroup: rbac.authorization.k8s.io
 `)
 
-// RoleBinding creates a RoleBinding
-func RoleBinding(owner *ibmcloudv1alpha1.Nfs, client client.Client, scheme *runtime.Scheme, log logr.Logger) *ResRoleBinding {
+// RoleBinding creates a RoleBinding from its manifest template
+func RoleBinding(owner *ibmcloudv1alpha1.Nfs, manifests resources.Manifests, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) (*ResRoleBinding, error) {
 	res := &ResRoleBinding{}
-	res.Resource = resources.New(owner, client, scheme, log)
-	res.Object = res.newRoleBinding()
+	res.Resource = resources.New(owner, client, scheme, recorder, log)
+	obj, err := res.newRoleBinding(manifests)
+	if err != nil {
+		return nil, err
+	}
+	res.Object = obj
 	apiVersion, kind := resources.GVK(res.Object, res.Scheme)
 	res.Log = res.Log.WithValues("Resource.Name", res.Object.GetName(), "Resource.Namespace", res.Object.GetNamespace(), "Resource.APIVersion", apiVersion, "Resource.Kind", kind)
 
-	return res
+	return res, nil
 }
 
 // Get returns the Object from the cluster
@@ -55,22 +62,14 @@ func (r *ResRoleBinding) Get() (runtime.Object, error) {
 	return r.getRoleBinding()
 }
 
-// Apply creates the Object if it does not exists
-func (r *ResRoleBinding) Apply() error {
-	_, err := r.getRoleBinding()
-	exists, err := resources.Exists(err)
-	if exists {
-		r.Log.Info("Skip reconcile: Resource already exists")
-		return nil
-	}
-	if err != nil {
-		r.Log.Error(err, "Failed to reconcile the resource")
-		return err
-	}
+// Type returns an empty object of the type of this resource, to watch it
+func (r *ResRoleBinding) Type() runtime.Object {
+	return &rbacv1.RoleBinding{}
+}
 
-	// if not exists and no error, then create
-	r.Log.Info("Created a new resource")
-	return r.Client.Create(context.TODO(), r.Object)
+// Apply creates or updates the Object with server-side apply
+func (r *ResRoleBinding) Apply() error {
+	return r.ServerSideApply(r.Object)
 }
 
 // Reconcile creates the Object if it does not exists and sets the Owner as an
@@ -89,26 +88,18 @@ func (r *ResRoleBinding) Reconcile() (reconcile.Result, error) {
 	return reconcile.Result{}, err
 }
 
-// newRoleBinding returns the definition of this resource as should exists
-func (r *ResRoleBinding) newRoleBinding() *rbacv1.RoleBinding {
-	return &rbacv1.RoleBinding{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "leader-locking-" + appName,
-			Namespace: r.Owner.Namespace,
-		},
-		Subjects: []rbacv1.Subject{
-			{
-				Kind:      "ServiceAccount",
-				Name:      appName,
-				Namespace: r.Owner.Namespace,
-			},
-		},
-		RoleRef: rbacv1.RoleRef{
-			Kind:     "Role",
-			Name:     "leader-locking-" + appName,
-			APIGroup: "rbac.authorization.k8s.io",
-		},
+// newRoleBinding returns the definition of this resource as should exists,
+// rendered from its manifest template. The name and namespace are set by the
+// operator, the template cannot change them
+func (r *ResRoleBinding) newRoleBinding(manifests resources.Manifests) (*rbacv1.RoleBinding, error) {
+	rb := &rbacv1.RoleBinding{}
+	if err := manifests.Decode(manifestRoleBinding, contentRoleBinding, resources.NewTemplateData(r.Owner), rb); err != nil {
+		return nil, err
 	}
+	rb.Name = leaderLockingName(r.Owner)
+	rb.Namespace = r.Owner.Namespace
+
+	return rb, nil
 }
 
 func (r *ResRoleBinding) getRoleBinding() (*rbacv1.RoleBinding, error) {
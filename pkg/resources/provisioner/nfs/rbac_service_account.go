@@ -8,8 +8,8 @@ import (
 	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
 	"github.com/johandry/nfs-operator/pkg/resources"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -23,22 +23,30 @@ type ResServiceAccount struct {
 	resources.Resource
 }
 
+// manifestServiceAccount is the name of the ServiceAccount manifest template
+const manifestServiceAccount = "service-account.yaml"
+
 var contentServiceAccount = []byte(`
 apiVersion: v1
 kind: ServiceAccount
 metadata:
-  name: nfs-provisioner
+  name: {{ .AppName }}
+  namespace: {{ .Namespace }}
 `)
 
-// ServiceAccount creates a ServiceAccount
-func ServiceAccount(owner *ibmcloudv1alpha1.Nfs, client client.Client, scheme *runtime.Scheme, log logr.Logger) *ResServiceAccount {
+// ServiceAccount creates a ServiceAccount from its manifest template
+func ServiceAccount(owner *ibmcloudv1alpha1.Nfs, manifests resources.Manifests, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) (*ResServiceAccount, error) {
 	res := &ResServiceAccount{}
-	res.Resource = resources.New(owner, client, scheme, log)
-	res.Object = res.newServiceAccount()
+	res.Resource = resources.New(owner, client, scheme, recorder, log)
+	obj, err := res.newServiceAccount(manifests)
+	if err != nil {
+		return nil, err
+	}
+	res.Object = obj
 	apiVersion, kind := resources.GVK(res.Object, res.Scheme)
 	res.Log = res.Log.WithValues("Resource.Name", res.Object.GetName(), "Resource.Namespace", res.Object.GetNamespace(), "Resource.APIVersion", apiVersion, "Resource.Kind", kind)
 
-	return res
+	return res, nil
 }
 
 // Get returns the Object from the cluster
@@ -46,22 +54,14 @@ func (r *ResServiceAccount) Get() (runtime.Object, error) {
 	return r.getServiceAccount()
 }
 
-// Apply creates the Object if it does not exists
-func (r *ResServiceAccount) Apply() error {
-	_, err := r.getServiceAccount()
-	exists, err := resources.Exists(err)
-	if exists {
-		r.Log.Info("Skip reconcile: Resource already exists")
-		return nil
-	}
-	if err != nil {
-		r.Log.Error(err, "Failed to reconcile the resource")
-		return err
-	}
+// Type returns an empty object of the type of this resource, to watch it
+func (r *ResServiceAccount) Type() runtime.Object {
+	return &corev1.ServiceAccount{}
+}
 
-	// if not exists and no error, then create
-	r.Log.Info("Created a new resource")
-	return r.Client.Create(context.TODO(), r.Object)
+// Apply creates or updates the Object with server-side apply
+func (r *ResServiceAccount) Apply() error {
+	return r.ServerSideApply(r.Object)
 }
 
 // Reconcile creates the Object if it does not exists and sets the Owner as an
@@ -80,14 +80,18 @@ func (r *ResServiceAccount) Reconcile() (reconcile.Result, error) {
 	return reconcile.Result{}, err
 }
 
-// newServiceAccount returns the definition of this resource as should exists
-func (r *ResServiceAccount) newServiceAccount() *corev1.ServiceAccount {
-	return &corev1.ServiceAccount{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      appName,
-			Namespace: r.Owner.Namespace,
-		},
+// newServiceAccount returns the definition of this resource as should exists,
+// rendered from its manifest template. The name and namespace are set by the
+// operator, the template cannot change them
+func (r *ResServiceAccount) newServiceAccount(manifests resources.Manifests) (*corev1.ServiceAccount, error) {
+	sa := &corev1.ServiceAccount{}
+	if err := manifests.Decode(manifestServiceAccount, contentServiceAccount, resources.NewTemplateData(r.Owner), sa); err != nil {
+		return nil, err
 	}
+	sa.Name = resources.AppName(r.Owner)
+	sa.Namespace = r.Owner.Namespace
+
+	return sa, nil
 }
 
 func (r *ResServiceAccount) getServiceAccount() (*corev1.ServiceAccount, error) {
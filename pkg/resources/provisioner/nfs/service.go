@@ -8,8 +8,8 @@ import (
 	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
 	"github.com/johandry/nfs-operator/pkg/resources"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -23,13 +23,17 @@ type ResService struct {
 	resources.Resource
 }
 
+// manifestService is the name of the Service manifest template
+const manifestService = "service.yaml"
+
 var contentService = []byte(`
 kind: Service
 apiVersion: v1
 metadata:
-  name: nfs-provisioner
+  name: {{ .AppName }}
+  namespace: {{ .Namespace }}
   labels:
-    app: nfs-provisioner
+    app: {{ .AppName }}
 spec:
   ports:
     - name: nfs
@@ -63,18 +67,22 @@ spec:
       port: 662
       protocol: UDP
   selector:
-    app: nfs-provisioner
+    app: {{ .AppName }}
 `)
 
-// Service creates a Service
-func Service(owner *ibmcloudv1alpha1.Nfs, client client.Client, scheme *runtime.Scheme, log logr.Logger) *ResService {
+// Service creates a Service from its manifest template
+func Service(owner *ibmcloudv1alpha1.Nfs, manifests resources.Manifests, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) (*ResService, error) {
 	res := &ResService{}
-	res.Resource = resources.New(owner, client, scheme, log)
-	res.Object = res.newService()
+	res.Resource = resources.New(owner, client, scheme, recorder, log)
+	obj, err := res.newService(manifests)
+	if err != nil {
+		return nil, err
+	}
+	res.Object = obj
 	apiVersion, kind := resources.GVK(res.Object, res.Scheme)
 	res.Log = res.Log.WithValues("Resource.Name", res.Object.GetName(), "Resource.Namespace", res.Object.GetNamespace(), "Resource.APIVersion", apiVersion, "Resource.Kind", kind)
 
-	return res
+	return res, nil
 }
 
 // Get returns the Object from the cluster
@@ -82,22 +90,14 @@ func (r *ResService) Get() (runtime.Object, error) {
 	return r.getService()
 }
 
-// Apply creates the Object if it does not exists
-func (r *ResService) Apply() error {
-	_, err := r.getService()
-	exists, err := resources.Exists(err)
-	if exists {
-		r.Log.Info("Skip reconcile: Resource already exists")
-		return nil
-	}
-	if err != nil {
-		r.Log.Error(err, "Failed to reconcile the resource")
-		return err
-	}
+// Type returns an empty object of the type of this resource, to watch it
+func (r *ResService) Type() runtime.Object {
+	return &corev1.Service{}
+}
 
-	// if not exists and no error, then create
-	r.Log.Info("Created a new resource")
-	return r.Client.Create(context.TODO(), r.Object)
+// Apply creates or updates the Object with server-side apply
+func (r *ResService) Apply() error {
+	return r.ServerSideApply(r.Object)
 }
 
 // Reconcile creates the Object if it does not exists and sets the Owner as an
@@ -116,78 +116,18 @@ func (r *ResService) Reconcile() (reconcile.Result, error) {
 	return reconcile.Result{}, err
 }
 
-// newService returns the definition of this resource as should exists
-func (r *ResService) newService() *corev1.Service {
-	return &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      appName,
-			Namespace: r.Owner.Namespace,
-			Labels: map[string]string{
-				"app": appName,
-			},
-		},
-		Spec: corev1.ServiceSpec{
-			Ports: []corev1.ServicePort{
-				{
-					Name: "nfs",
-					Port: int32(2049),
-				},
-				{
-					Name:     "nfs-udp",
-					Port:     int32(2049),
-					Protocol: corev1.ProtocolUDP,
-				},
-				{
-					Name: "nlockmgr",
-					Port: int32(32803),
-				},
-				{
-					Name:     "nlockmgr-udp",
-					Port:     int32(32803),
-					Protocol: corev1.ProtocolUDP,
-				},
-				{
-					Name: "mountd",
-					Port: int32(20048),
-				},
-				{
-					Name:     "mountd-udp",
-					Port:     int32(20048),
-					Protocol: corev1.ProtocolUDP,
-				},
-				{
-					Name: "rquotad",
-					Port: int32(875),
-				},
-				{
-					Name:     "rquotad-udp",
-					Port:     int32(875),
-					Protocol: corev1.ProtocolUDP,
-				},
-				{
-					Name: "rpcbind",
-					Port: int32(111),
-				},
-				{
-					Name:     "rpcbind-udp",
-					Port:     int32(111),
-					Protocol: corev1.ProtocolUDP,
-				},
-				{
-					Name: "statd",
-					Port: int32(662),
-				},
-				{
-					Name:     "statd-udp",
-					Port:     int32(662),
-					Protocol: corev1.ProtocolUDP,
-				},
-			},
-			Selector: map[string]string{
-				"app": appName,
-			},
-		},
+// newService returns the definition of this resource as should exists,
+// rendered from its manifest template. The name and namespace are set by the
+// operator, the template cannot change them
+func (r *ResService) newService(manifests resources.Manifests) (*corev1.Service, error) {
+	svc := &corev1.Service{}
+	if err := manifests.Decode(manifestService, contentService, resources.NewTemplateData(r.Owner), svc); err != nil {
+		return nil, err
 	}
+	svc.Name = resources.AppName(r.Owner)
+	svc.Namespace = r.Owner.Namespace
+
+	return svc, nil
 }
 
 func (r *ResService) getService() (*corev1.Service, error) {
@@ -3,46 +3,80 @@ package nfs
 import (
 	"context"
 	"fmt"
+	"reflect"
 
 	"github.com/go-logr/logr"
 	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
 	"github.com/johandry/nfs-operator/pkg/resources"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 var _ resources.Reconcilable = &ResStorageClass{}
+var _ resources.Observable = &ResStorageClass{}
+var _ resources.Finalizable = &ResStorageClass{}
 
-// ResStorageClass is the resource StorageClass
+// ResStorageClass is the resource StorageClass, the StorageClass of the Nfs or
+// one of its StorageClass profiles
 type ResStorageClass struct {
 	Object *storagev1.StorageClass
 	resources.Resource
+	// profile is nil for the StorageClass of the Nfs
+	profile *ibmcloudv1alpha1.StorageClassProfile
 }
 
+// manifestStorageClass is the name of the StorageClass manifest template
+const manifestStorageClass = "storage-class.yaml"
+
+// contentStorageClass is rendered with the storageClassData. The settings of
+// the Nfs storageClassTemplate are applied over the rendered StorageClass
 var contentStorageClass = []byte(`
 kind: StorageClass
 apiVersion: storage.k8s.io/v1
 metadata:
-  name: ibmcloud-nfs
-provisioner: ibmcloud/nfs
+  name: {{ .StorageClassName }}
+provisioner: {{ .ProvisionerName }}
 mountOptions:
-	- vers=4.1
+  - vers={{ .NFSVersion }}
 `)
 
-// StorageClass creates a StorageClass
-func StorageClass(owner *ibmcloudv1alpha1.Nfs, client client.Client, scheme *runtime.Scheme, log logr.Logger) *ResStorageClass {
-	res := &ResStorageClass{}
-	res.Resource = resources.New(owner, client, scheme, log)
-	res.Object = res.newStorageClass()
+// storageClassData is the data to render the StorageClass manifest template
+type storageClassData struct {
+	resources.TemplateData
+	// NFSVersion is the NFS version mounted by the clients, 4.1 unless the
+	// exports serve only the NFS version 3
+	NFSVersion string
+}
+
+// StorageClass creates a StorageClass from its manifest template
+func StorageClass(owner *ibmcloudv1alpha1.Nfs, manifests resources.Manifests, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) (*ResStorageClass, error) {
+	return newResStorageClass(owner, nil, manifests, client, scheme, recorder, log)
+}
+
+// StorageClassProfile creates the StorageClass of a StorageClass profile from
+// the same manifest template, with the settings of the profile
+func StorageClassProfile(owner *ibmcloudv1alpha1.Nfs, profile ibmcloudv1alpha1.StorageClassProfile, manifests resources.Manifests, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) (*ResStorageClass, error) {
+	return newResStorageClass(owner, profile.DeepCopy(), manifests, client, scheme, recorder, log)
+}
+
+func newResStorageClass(owner *ibmcloudv1alpha1.Nfs, profile *ibmcloudv1alpha1.StorageClassProfile, manifests resources.Manifests, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) (*ResStorageClass, error) {
+	res := &ResStorageClass{profile: profile}
+	res.Resource = resources.New(owner, client, scheme, recorder, log)
+	obj, err := res.newStorageClass(manifests)
+	if err != nil {
+		return nil, err
+	}
+	res.Object = obj
 	apiVersion, kind := resources.GVK(res.Object, res.Scheme)
 	res.Log = res.Log.WithValues("Resource.Name", res.Object.GetName(), "Resource.Namespace", res.Object.GetNamespace(), "Resource.APIVersion", apiVersion, "Resource.Kind", kind)
 
-	return res
+	return res, nil
 }
 
 // Get returns the Object from the cluster
@@ -50,51 +84,369 @@ func (r *ResStorageClass) Get() (runtime.Object, error) {
 	return r.getStorageClass()
 }
 
-// Apply creates the Object if it does not exists
+// Type returns an empty object of the type of this resource, to watch it
+func (r *ResStorageClass) Type() runtime.Object {
+	return &storagev1.StorageClass{}
+}
+
+// Apply creates or updates the Object with server-side apply
 func (r *ResStorageClass) Apply() error {
-	_, err := r.getStorageClass()
+	return r.ServerSideApply(r.Object)
+}
+
+// Reconcile creates the Object if it does not exists. The StorageClass is
+// cluster scoped so it cannot be owned by the Nfs, instead it's labeled with the
+// owner and deleted by Finalize when the Nfs is deleted. The provisioner,
+// parameters, reclaim policy and volume binding mode cannot be updated, if they
+// changed the StorageClass is deleted and created again by Apply. The existing
+// volumes and claims only refer to the StorageClass by name, they are not
+// affected. The StorageClass of the Nfs also deletes the StorageClass profiles
+// removed from the spec
+func (r *ResStorageClass) Reconcile() (reconcile.Result, error) {
+	if r.Owner == nil {
+		return reconcile.Result{}, fmt.Errorf("the resource %s does not have an owner", r.Object.Name)
+	}
+	r.Log.Info("Reconciling " + r.Object.Name + " resource")
+	if err := r.conflict(); err != nil {
+		r.Log.Error(err, "Failed to reconcile the resource")
+		return reconcile.Result{}, err
+	}
+
+	if r.profile == nil {
+		if err := r.cleanup(); err != nil {
+			r.Log.Error(err, "Failed to delete the removed StorageClass profiles")
+			return reconcile.Result{}, err
+		}
+	}
+
+	found, err := r.getStorageClass()
 	exists, err := resources.Exists(err)
-	if exists {
-		r.Log.Info("Skip reconcile: Resource already exists")
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if exists && r.immutableChanged(found) {
+		r.Log.Info("Deleting the StorageClass to create it again, an immutable field changed")
+		if err := r.Delete(found); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	if err := r.refuseDefault(); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	err = r.Apply()
+
+	return reconcile.Result{}, err
+}
+
+// immutableChanged returns true if the fields of the StorageClass that cannot
+// be updated are not the requested. The reclaim policy and volume binding mode
+// not requested are the defaults set by the API server
+func (r *ResStorageClass) immutableChanged(found *storagev1.StorageClass) bool {
+	reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+	if r.Object.ReclaimPolicy != nil {
+		reclaimPolicy = *r.Object.ReclaimPolicy
+	}
+	bindingMode := storagev1.VolumeBindingImmediate
+	if r.Object.VolumeBindingMode != nil {
+		bindingMode = *r.Object.VolumeBindingMode
+	}
+
+	if found.Provisioner != r.Object.Provisioner {
+		return true
+	}
+	if (len(found.Parameters) != 0 || len(r.Object.Parameters) != 0) && !reflect.DeepEqual(found.Parameters, r.Object.Parameters) {
+		return true
+	}
+	if found.ReclaimPolicy != nil && *found.ReclaimPolicy != reclaimPolicy {
+		return true
+	}
+	if found.VolumeBindingMode != nil && *found.VolumeBindingMode != bindingMode {
+		return true
+	}
+	return false
+}
+
+// cleanup deletes the StorageClasses owned by the Nfs that are not its
+// StorageClass or one of its StorageClass profiles
+func (r *ResStorageClass) cleanup() error {
+	list := &storagev1.StorageClassList{}
+	if err := r.Client.List(context.TODO(), list, client.MatchingLabels(r.OwnerLabels())); err != nil {
+		return err
+	}
+
+	names := map[string]bool{}
+	for _, name := range resources.StorageClassNames(r.Owner) {
+		names[name] = true
+	}
+	for i := range list.Items {
+		sc := &list.Items[i]
+		if names[sc.Name] {
+			continue
+		}
+		r.Log.Info("Deleting the removed StorageClass profile", "StorageClass.Name", sc.Name)
+		if err := r.Delete(sc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// template returns the settings of this StorageClass, from the Nfs or from
+// the StorageClass profile
+func (r *ResStorageClass) template() ibmcloudv1alpha1.StorageClassTemplateSpec {
+	if r.profile != nil {
+		return r.profile.StorageClassTemplateSpec
+	}
+	return r.Owner.Spec.StorageClassTemplate
+}
+
+// refuseDefault removes the default class annotation from the Object if the
+// Nfs requests a default StorageClass but other StorageClass is the default
+func (r *ResStorageClass) refuseDefault() error {
+	if !r.template().Default {
 		return nil
 	}
+	other, err := r.otherDefault()
 	if err != nil {
-		r.Log.Error(err, "Failed to reconcile the resource")
 		return err
 	}
+	if len(other) != 0 {
+		r.Log.Info("Skip default StorageClass: other StorageClass is the default", "StorageClass.Default", other)
+		delete(r.Object.Annotations, ibmcloudv1alpha1.IsDefaultClassAnnotation)
+	}
+	return nil
+}
+
+// otherDefault returns the name of the StorageClass, other than the Object,
+// marked as the cluster default. It's empty if there is none
+func (r *ResStorageClass) otherDefault() (string, error) {
+	list := &storagev1.StorageClassList{}
+	if err := r.Client.List(context.TODO(), list); err != nil {
+		return "", err
+	}
+	for i := range list.Items {
+		sc := &list.Items[i]
+		if sc.Name != r.Object.Name && isDefaultClass(sc) {
+			return sc.Name, nil
+		}
+	}
+	return "", nil
+}
 
-	// if not exists and no error, then create
-	r.Log.Info("Created a new resource")
-	return r.Client.Create(context.TODO(), r.Object)
+// isDefaultClass returns true if the StorageClass is marked as the cluster
+// default, with the annotation or the beta annotation
+func isDefaultClass(sc *storagev1.StorageClass) bool {
+	return sc.Annotations[ibmcloudv1alpha1.IsDefaultClassAnnotation] == "true" ||
+		sc.Annotations[ibmcloudv1alpha1.BetaIsDefaultClassAnnotation] == "true"
 }
 
-// Reconcile creates the Object if it does not exists and sets the Owner as an
-// owner reference on the Object
-func (r *ResStorageClass) Reconcile() (reconcile.Result, error) {
-	if r.Owner == nil {
-		return reconcile.Result{}, fmt.Errorf("the resource %s/%s does not have an owner", r.Object.Namespace, r.Object.Name)
+// conflict returns a ConflictError if the StorageClass exists but it's not
+// owned by the Nfs, or if other Nfs is using the same provisioner name
+func (r *ResStorageClass) conflict() error {
+	found, err := r.getStorageClass()
+	exists, err := resources.Exists(err)
+	if err != nil {
+		return err
 	}
-	r.Log.Info("Reconciling " + r.Object.Name + " resource")
-	if err := controllerutil.SetControllerReference(r.Owner, r.Object, r.Scheme); err != nil {
-		r.Log.Error(err, "Failed to set controller reference to resource")
-		return reconcile.Result{}, err
+	if exists && !r.IsOwned(found) {
+		owner := resources.OwnerOf(found)
+		if len(owner) == 0 {
+			return resources.NewConflictError("StorageClass", found.Name, "it's not owned by any Nfs")
+		}
+		return resources.NewConflictError("StorageClass", found.Name, "it's owned by the Nfs %s", owner)
 	}
-	err := r.Apply()
 
-	return reconcile.Result{}, err
+	list := &storagev1.StorageClassList{}
+	if err := r.Client.List(context.TODO(), list, client.HasLabels{resources.LabelOwnerName}); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		sc := &list.Items[i]
+		if sc.Provisioner == r.Object.Provisioner && !r.IsOwned(sc) {
+			return resources.NewConflictError("StorageClass", sc.Name, "the provisioner %s is used by the Nfs %s", sc.Provisioner, resources.OwnerOf(sc))
+		}
+	}
+
+	return nil
+}
+
+// Finalize deletes every StorageClass owned by the Nfs, including the
+// StorageClass profiles removed from the spec but not deleted yet
+func (r *ResStorageClass) Finalize() error {
+	list := &storagev1.StorageClassList{}
+	if err := r.Client.List(context.TODO(), list, client.MatchingLabels(r.OwnerLabels())); err != nil {
+		return err
+	}
+
+	for i := range list.Items {
+		if err := r.Delete(&list.Items[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Observe sets the StorageClassReady condition on the given status, and the
+// DefaultStorageClass condition if the Nfs requests a default StorageClass.
+// The StorageClass of the Nfs is observed first, the StorageClass profiles only
+// set the StorageClassReady condition if it's still True, so it reports the
+// first StorageClass that is not ready
+func (r *ResStorageClass) Observe(st *ibmcloudv1alpha1.NfsStatus) error {
+	found, err := r.getStorageClass()
+	exists, err := resources.Exists(err)
+	if err != nil {
+		return err
+	}
+
+	cond := status.Condition{
+		Type:   ibmcloudv1alpha1.ConditionStorageClassReady,
+		Status: corev1.ConditionFalse,
+	}
+
+	if conflictErr := r.conflict(); resources.IsConflict(conflictErr) {
+		cond.Reason = "Conflict"
+		cond.Message = conflictErr.Error()
+		r.setReadyCondition(st, cond)
+		return nil
+	}
+
+	switch {
+	case !exists:
+		cond.Reason = "NotFound"
+		cond.Message = fmt.Sprintf("the storage class %s does not exists", r.Object.Name)
+	case found.Provisioner != r.Object.Provisioner:
+		cond.Reason = "ProvisionerMismatch"
+		cond.Message = fmt.Sprintf("the storage class %s uses the provisioner %s instead of %s", found.Name, found.Provisioner, r.Object.Provisioner)
+	default:
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = "Created"
+		cond.Message = fmt.Sprintf("the storage class %s is available", found.Name)
+	}
+
+	r.setReadyCondition(st, cond)
+
+	return r.observeDefault(st, found)
+}
+
+// setReadyCondition sets the StorageClassReady condition, for a StorageClass
+// profile only if the other StorageClasses are ready
+func (r *ResStorageClass) setReadyCondition(st *ibmcloudv1alpha1.NfsStatus, cond status.Condition) {
+	if r.profile != nil && !st.Conditions.IsTrueFor(ibmcloudv1alpha1.ConditionStorageClassReady) {
+		return
+	}
+	st.Conditions.SetCondition(cond)
 }
 
-// newStorageClass returns the definition of this resource as should exists
-func (r *ResStorageClass) newStorageClass() *storagev1.StorageClass {
-	return &storagev1.StorageClass{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      storageClassName,
-			Namespace: r.Owner.Namespace,
-		},
-		Provisioner: provisionerName,
-		MountOptions: []string{
-			"vers=4.1",
-		},
+// observeDefault sets the DefaultStorageClass condition if this StorageClass is
+// requested as the default. The StorageClass of the Nfs removes it if no
+// StorageClass is requested as the default
+func (r *ResStorageClass) observeDefault(st *ibmcloudv1alpha1.NfsStatus, found *storagev1.StorageClass) error {
+	if r.profile == nil && !requestsDefault(r.Owner) {
+		st.Conditions.RemoveCondition(ibmcloudv1alpha1.ConditionDefaultStorageClass)
+		return nil
+	}
+	if !r.template().Default {
+		return nil
+	}
+
+	cond := status.Condition{
+		Type:   ibmcloudv1alpha1.ConditionDefaultStorageClass,
+		Status: corev1.ConditionFalse,
+	}
+
+	other, err := r.otherDefault()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case found != nil && isDefaultClass(found):
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = "Default"
+		cond.Message = fmt.Sprintf("the storage class %s is the cluster default", found.Name)
+	case len(other) != 0:
+		cond.Reason = "DefaultClassExists"
+		cond.Message = fmt.Sprintf("the storage class %s is already the cluster default", other)
+	default:
+		cond.Reason = "Pending"
+		cond.Message = fmt.Sprintf("the storage class %s is not the cluster default yet", r.Object.Name)
+	}
+
+	st.Conditions.SetCondition(cond)
+
+	return nil
+}
+
+// requestsDefault returns true if the Nfs requests its StorageClass or any of
+// its StorageClass profiles as the cluster default
+func requestsDefault(owner *ibmcloudv1alpha1.Nfs) bool {
+	if owner.Spec.StorageClassTemplate.Default {
+		return true
+	}
+	for _, p := range owner.Spec.StorageClassProfiles {
+		if p.Default {
+			return true
+		}
+	}
+	return false
+}
+
+// newStorageClass returns the definition of this resource as should exists,
+// rendered from its manifest template with the storageClassTemplate settings
+// of the Nfs, or the settings of the StorageClass profile. The name and the
+// owner labels are set by the operator, the template cannot change them
+func (r *ResStorageClass) newStorageClass(manifests resources.Manifests) (*storagev1.StorageClass, error) {
+	sc := &storagev1.StorageClass{}
+	data := storageClassData{
+		TemplateData: resources.NewTemplateData(r.Owner),
+		NFSVersion:   "4.1",
+	}
+	if r.profile != nil {
+		data.StorageClassName = r.profile.Name
+	}
+	if servesOnlyNFSv3(r.Owner) {
+		data.NFSVersion = "3"
+	}
+	if err := manifests.Decode(manifestStorageClass, contentStorageClass, data, sc); err != nil {
+		return nil, err
+	}
+	r.applyStorageClassTemplate(sc)
+	sc.Name = data.StorageClassName
+	sc.Labels = r.WithOwnerLabels(sc.Labels)
+
+	return sc, nil
+}
+
+// applyStorageClassTemplate applies the settings of this StorageClass to the
+// rendered StorageClass. The labels, annotations and parameters are merged, the
+// other fields are replaced if they are set
+func (r *ResStorageClass) applyStorageClassTemplate(sc *storagev1.StorageClass) {
+	t := r.template()
+
+	sc.Labels = mergeMap(sc.Labels, t.Labels)
+	sc.Annotations = mergeMap(sc.Annotations, t.Annotations)
+	sc.Parameters = mergeMap(sc.Parameters, t.Parameters)
+	if len(t.MountOptions) != 0 {
+		sc.MountOptions = append([]string{}, t.MountOptions...)
+	}
+	if t.ReclaimPolicy != nil {
+		policy := *t.ReclaimPolicy
+		sc.ReclaimPolicy = &policy
+	}
+	if t.AllowVolumeExpansion != nil {
+		allow := *t.AllowVolumeExpansion
+		sc.AllowVolumeExpansion = &allow
+	}
+	if t.VolumeBindingMode != nil {
+		mode := *t.VolumeBindingMode
+		sc.VolumeBindingMode = &mode
+	}
+	if t.Default {
+		sc.Annotations = mergeMap(sc.Annotations, map[string]string{ibmcloudv1alpha1.IsDefaultClassAnnotation: "true"})
 	}
 }
 
@@ -1,42 +1,152 @@
 package resources
 
 import (
+	"context"
+
 	"github.com/go-logr/logr"
 	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-// Reconcilable is a resource that can be reconciled by the controller
+// Reconcilable is a resource that can be reconciled by the controller. Apply
+// creates or updates the resource with server-side apply, the operator owns only
+// the fields set on the desired state. Type
+// returns an empty object of the managed type, the controller watches it
 type Reconcilable interface {
+	Type() runtime.Object
 	Get() (runtime.Object, error)
 	Apply() error
 	Reconcile() (reconcile.Result, error)
 }
 
+// Observable is a resource that reports its state in the cluster on the status
+// of the owner, usually as a condition
+type Observable interface {
+	Observe(status *ibmcloudv1alpha1.NfsStatus) error
+}
+
+// Sized is an Observable backing storage that reports the capacity it observed,
+// the status has it only as a string
+type Sized interface {
+	// Capacity returns the capacity of the bound storage, or false if it's not
+	// bound or it's unknown
+	Capacity() (resource.Quantity, bool)
+}
+
+// Finalizable is a resource that has to be cleaned up by the operator when the
+// owner is deleted, usually because the garbage collector cannot do it
+type Finalizable interface {
+	Finalize() error
+}
+
+const (
+	// LabelOwnerName is the label with the name of the Nfs that owns a resource
+	// that cannot have an owner reference, like the cluster scoped resources
+	LabelOwnerName = "ibmcloud.ibm.com/nfs-name"
+	// LabelOwnerNamespace is the label with the namespace of the Nfs that owns a
+	// resource that cannot have an owner reference
+	LabelOwnerNamespace = "ibmcloud.ibm.com/nfs-namespace"
+)
+
 // Resource is the resource Unstructured
 type Resource struct {
 	Client client.Client
 	Scheme *runtime.Scheme
 	Owner  *ibmcloudv1alpha1.Nfs
-	Log    logr.Logger
+	// Recorder emits the Events of the resource on the Owner
+	Recorder record.EventRecorder
+	Log      logr.Logger
 }
 
 // New creates a Resource which can Reconcile
-func New(owner *ibmcloudv1alpha1.Nfs, client client.Client, scheme *runtime.Scheme, log logr.Logger) Resource {
+func New(owner *ibmcloudv1alpha1.Nfs, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) Resource {
 	res := Resource{
-		Client: client,
-		Scheme: scheme,
-		Owner:  owner,
-		Log:    log,
+		Client:   client,
+		Scheme:   scheme,
+		Owner:    owner,
+		Recorder: recorder,
+		Log:      log,
 	}
 
 	return res
 }
 
+// OwnerLabels returns the labels to identify the owner of a resource that
+// cannot have an owner reference
+func (r Resource) OwnerLabels() map[string]string {
+	return map[string]string{
+		LabelOwnerName:      r.Owner.Name,
+		LabelOwnerNamespace: r.Owner.Namespace,
+	}
+}
+
+// WithOwnerLabels returns the given labels with the owner labels, the owner
+// labels cannot be overridden
+func (r Resource) WithOwnerLabels(labels map[string]string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	for k, v := range r.OwnerLabels() {
+		merged[k] = v
+	}
+	return merged
+}
+
+// IsOwned returns true if the given object is labeled as owned by the owner or
+// has the owner in the owner references
+func (r Resource) IsOwned(obj metav1.Object) bool {
+	labels := obj.GetLabels()
+	if labels[LabelOwnerName] == r.Owner.Name && labels[LabelOwnerNamespace] == r.Owner.Namespace {
+		return true
+	}
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == r.Owner.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// OwnerOf returns the namespace and name of the Nfs that owns the given object
+// according to its labels, or an empty string if it's not owned by any Nfs
+func OwnerOf(obj metav1.Object) string {
+	labels := obj.GetLabels()
+	name, ok := labels[LabelOwnerName]
+	if !ok {
+		return ""
+	}
+	return labels[LabelOwnerNamespace] + "/" + name
+}
+
+// Delete deletes the given object from the cluster, it's not an error if the
+// object does not exists
+func (r Resource) Delete(obj runtime.Object) error {
+	r.Log.Info("Deleting the resource")
+	err := r.Client.Delete(context.TODO(), obj)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		r.Log.Error(err, "Failed to delete the resource")
+		return err
+	}
+	_, kind := GVK(obj, r.Scheme)
+	if accessor, err := meta.Accessor(obj); err == nil {
+		r.Eventf(corev1.EventTypeNormal, ReasonDeleted, "Deleted the %s %s", kind, accessor.GetName())
+	}
+	return nil
+}
+
 // GVK returns the API Version and Kind of a given resource
 func GVK(ro runtime.Object, scheme *runtime.Scheme) (apiVersion string, kind string) {
 	gvk, err := apiutil.GVKForObject(ro, scheme)
@@ -0,0 +1,93 @@
+package resources
+
+import (
+	"bytes"
+	"encoding/json"
+	"text/template"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"sigs.k8s.io/yaml"
+)
+
+// TemplateData is the data available to the templates of the manifests, the
+// names of the Nfs and of the resources created for it
+type TemplateData struct {
+	// Name and Namespace of the Nfs
+	Name      string
+	Namespace string
+	// AppName is the value of the label "app" of the NFS provisioner Pods, it's
+	// also the name of its Service and ServiceAccount
+	AppName          string
+	StorageClassName string
+	// StorageClassNames are the StorageClassName and the names of the
+	// StorageClass profiles
+	StorageClassNames []string
+	ProvisionerName   string
+	ClaimName         string
+	// Spec is the spec of the Nfs
+	Spec ibmcloudv1alpha1.NfsSpec
+}
+
+// NewTemplateData returns the data to render the manifests templates of the
+// given Nfs
+func NewTemplateData(owner *ibmcloudv1alpha1.Nfs) TemplateData {
+	return TemplateData{
+		Name:              owner.Name,
+		Namespace:         owner.Namespace,
+		AppName:           AppName(owner),
+		StorageClassName:  StorageClassName(owner),
+		StorageClassNames: StorageClassNames(owner),
+		ProvisionerName:   ProvisionerName(owner),
+		ClaimName:         BackingStorageClaimName(owner),
+		Spec:              owner.Spec,
+	}
+}
+
+// templateFuncs are the functions available to the templates of the manifests
+var templateFuncs = template.FuncMap{
+	// toJson returns the JSON encoding of the value, it's also valid YAML
+	"toJson": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// Render executes the given manifest as a template with the given data. It's an
+// error to use a field that is not in the data
+func Render(name string, manifest []byte, data interface{}) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(string(manifest))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Manifests are the templates of the manifests of the resources by name, that
+// override the templates embedded in the operator. Usually it's the data of a
+// ConfigMap provided by the cluster admin
+type Manifests map[string]string
+
+// Decode renders the manifest template with the given name and decodes it into
+// the given object. The template is the one with the same name in the
+// Manifests, or the given default template if there is none. Any error is
+// returned as a TemplateError
+func (m Manifests) Decode(name string, defaultTemplate []byte, data interface{}, obj interface{}) error {
+	manifest := defaultTemplate
+	if override, ok := m[name]; ok {
+		manifest = []byte(override)
+	}
+
+	content, err := Render(name, manifest, data)
+	if err != nil {
+		return NewTemplateError(name, err)
+	}
+	if err := yaml.UnmarshalStrict(content, obj); err != nil {
+		return NewTemplateError(name, err)
+	}
+	return nil
+}
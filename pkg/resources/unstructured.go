@@ -6,40 +6,39 @@ import (
 
 	"github.com/go-logr/logr"
 	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 var _ Reconcilable = &ResUnstructured{}
+var _ Finalizable = &ResUnstructured{}
 
-// ResUnstructured is the resource Unstructured
+// ResUnstructured is a resource of any kind, defined by an unstructured object
 type ResUnstructured struct {
-	group     string
-	kind      string
-	version   string
-	name      string
-	namespace string
-	Object    *unstructured.Unstructured
+	namespaced bool
+	Object     *unstructured.Unstructured
 	Resource
 }
 
-// Unstructured create a Unstructured object Resource
-func Unstructured(group, kind, version, name, namespace string, object map[string]interface{}, owner *ibmcloudv1alpha1.Nfs, client client.Client, scheme *runtime.Scheme, log logr.Logger) {
+// Unstructured creates a Resource of the kind of the given object. A namespaced
+// object is created in the owner namespace, a cluster scoped object is labeled
+// with the owner as it cannot have an owner reference
+func Unstructured(object *unstructured.Unstructured, namespaced bool, owner *ibmcloudv1alpha1.Nfs, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger) *ResUnstructured {
 	res := &ResUnstructured{
-		group:     group,
-		kind:      kind,
-		version:   version,
-		namespace: namespace,
+		namespaced: namespaced,
 	}
-	res.Resource = New(owner, client, scheme, log)
+	res.Resource = New(owner, client, scheme, recorder, log)
 	res.Object = res.newUnstructured(object)
 
-	apiVersion, kind := GVK(res.Object, res.Scheme)
+	apiVersion, kind := res.Object.GroupVersionKind().ToAPIVersionAndKind()
 	res.Log = res.Log.WithValues("Resource.Name", res.Object.GetName(), "Resource.Namespace", res.Object.GetNamespace(), "Resource.APIVersion", apiVersion, "Resource.Kind", kind)
+
+	return res
 }
 
 // Get returns the Object from the cluster
@@ -47,60 +46,112 @@ func (r *ResUnstructured) Get() (runtime.Object, error) {
 	return r.getUnstructured()
 }
 
-// Apply creates the Object if it does not exists
-func (r *ResUnstructured) Apply() error {
-	_, err := r.getUnstructured()
-	exists, err := Exists(err)
-	if exists || err != nil {
-		return err
-	}
+// Type returns an empty object of the type of this resource, to watch it
+func (r *ResUnstructured) Type() runtime.Object {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(r.Object.GroupVersionKind())
+	return u
+}
 
-	// if not exists and no error, then create
-	r.Log.Info("Created a new resource")
-	return r.Client.Create(context.TODO(), r.Object)
+// Apply creates or updates the Object with server-side apply
+func (r *ResUnstructured) Apply() error {
+	return r.ServerSideApply(r.Object)
 }
 
-// Reconcile creates the Object if it does not exists and sets the Owner as an
-// owner reference on the Object
+// Reconcile creates or updates the Object. A namespaced Object is owned by the
+// Nfs with an owner reference. A cluster scoped Object is labeled with the owner
+// and deleted by Finalize when the Nfs is deleted, if it exists and it's not
+// owned by the Nfs it's not modified
 func (r *ResUnstructured) Reconcile() (reconcile.Result, error) {
 	if r.Owner == nil {
 		return reconcile.Result{}, fmt.Errorf("the resource %s/%s does not have an owner", r.Object.GetNamespace(), r.Object.GetName())
 	}
-	if err := controllerutil.SetControllerReference(r.Owner, r.Object, r.Scheme); err != nil {
-		return reconcile.Result{}, err
+	r.Log.Info("Reconciling " + r.Object.GetName() + " resource")
+
+	if r.namespaced {
+		if err := controllerutil.SetControllerReference(r.Owner, r.Object, r.Scheme); err != nil {
+			return reconcile.Result{}, err
+		}
+	} else {
+		found, err := r.getUnstructured()
+		exists, err := Exists(err)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if exists && !r.IsOwned(found) {
+			err := NewConflictError(r.Object.GetKind(), found.GetName(), "it's not owned by the Nfs")
+			r.Log.Error(err, "Failed to reconcile the resource")
+			return reconcile.Result{}, err
+		}
 	}
+
 	err := r.Apply()
 
 	return reconcile.Result{}, err
 }
 
+// Finalize deletes the Object if it's cluster scoped and owned by the Nfs, the
+// namespaced Object is deleted by the garbage collector
+func (r *ResUnstructured) Finalize() error {
+	if r.namespaced {
+		return nil
+	}
+
+	found, err := r.getUnstructured()
+	exists, err := Exists(err)
+	if !exists || err != nil {
+		return err
+	}
+	if !r.IsOwned(found) {
+		r.Log.Info("Skip finalize: Resource is not owned by the Nfs")
+		return nil
+	}
+
+	return r.Delete(found)
+}
+
+// Prune deletes the Object, namespaced or cluster scoped, if it's owned by the
+// Nfs. It's an extra resource removed from the Nfs spec, if its kind is not
+// known anymore it's gone with its CRD
+func (r *ResUnstructured) Prune() error {
+	found, err := r.getUnstructured()
+	if meta.IsNoMatchError(err) {
+		return nil
+	}
+	exists, err := Exists(err)
+	if !exists || err != nil {
+		return err
+	}
+	if !r.IsOwned(found) {
+		r.Log.Info("Skip prune: Resource is not owned by the Nfs")
+		return nil
+	}
+
+	return r.Delete(found)
+}
+
 // newUnstructured returns the definition of this resource as should exists
-func (r *ResUnstructured) newUnstructured(object map[string]interface{}) *unstructured.Unstructured {
-	u := &unstructured.Unstructured{}
-	u.Object = object
+func (r *ResUnstructured) newUnstructured(object *unstructured.Unstructured) *unstructured.Unstructured {
+	u := object.DeepCopy()
 
-	u.SetGroupVersionKind(schema.GroupVersionKind{
-		Group:   r.group,
-		Kind:    r.kind,
-		Version: r.version,
-	})
+	if r.namespaced {
+		u.SetNamespace(r.Owner.Namespace)
+	} else {
+		u.SetNamespace("")
+	}
+
+	u.SetLabels(r.WithOwnerLabels(u.GetLabels()))
 
-	u.SetName(r.name)
-	u.SetNamespace(r.namespace)
 	return u
 }
 
 func (r *ResUnstructured) getUnstructured() (*unstructured.Unstructured, error) {
 	u := &unstructured.Unstructured{}
-	u.SetGroupVersionKind(schema.GroupVersionKind{
-		Group:   r.group,
-		Kind:    r.kind,
-		Version: r.version,
-	})
-
-	err := r.Client.Get(context.Background(), client.ObjectKey{
-		Namespace: r.namespace,
-		Name:      r.name,
+	u.SetGroupVersionKind(r.Object.GroupVersionKind())
+
+	err := r.Client.Get(context.TODO(), client.ObjectKey{
+		Namespace: r.Object.GetNamespace(),
+		Name:      r.Object.GetName(),
 	}, u)
 
 	if err == nil {
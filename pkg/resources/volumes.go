@@ -0,0 +1,41 @@
+package resources
+
+import (
+	"context"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PersistentVolumeStorageClassField is the index of the PersistentVolumes by
+// StorageClass, to find the volumes of a Nfs without listing every volume of
+// the cluster
+const PersistentVolumeStorageClassField = "spec.storageClassName"
+
+// IndexPersistentVolumes adds the PersistentVolumeStorageClassField index to
+// the given indexer, the cache of the manager before it starts
+func IndexPersistentVolumes(indexer client.FieldIndexer) error {
+	return indexer.IndexField(context.TODO(), &corev1.PersistentVolume{}, PersistentVolumeStorageClassField, func(obj runtime.Object) []string {
+		pv, ok := obj.(*corev1.PersistentVolume)
+		if !ok || len(pv.Spec.StorageClassName) == 0 {
+			return nil
+		}
+		return []string{pv.Spec.StorageClassName}
+	})
+}
+
+// ProvisionedVolumes returns the PersistentVolumes of the StorageClasses of the
+// given Nfs, from the cache indexed by StorageClass
+func ProvisionedVolumes(c client.Reader, owner *ibmcloudv1alpha1.Nfs) ([]corev1.PersistentVolume, error) {
+	volumes := []corev1.PersistentVolume{}
+	for _, name := range StorageClassNames(owner) {
+		list := &corev1.PersistentVolumeList{}
+		if err := c.List(context.TODO(), list, client.MatchingFields{PersistentVolumeStorageClassField: name}); err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, list.Items...)
+	}
+	return volumes, nil
+}
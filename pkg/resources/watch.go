@@ -0,0 +1,111 @@
+package resources
+
+import (
+	"context"
+	"sync"
+
+	ibmcloudv1alpha1 "github.com/johandry/nfs-operator/pkg/apis/ibmcloud/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Watcher watches the types of the resources owned by the Nfs, only once per
+// type. Any change on a resource sends a reconcile request for the Nfs that
+// owns it. The types can be added after the controller is started, like the
+// kinds of the extra resources that are known only from the Nfs spec
+type Watcher struct {
+	controller controller.Controller
+	scheme     *runtime.Scheme
+	mu         sync.Mutex
+	watched    map[schema.GroupVersionKind]bool
+}
+
+// NewWatcher creates a Watcher for the given controller
+func NewWatcher(c controller.Controller, scheme *runtime.Scheme) *Watcher {
+	return &Watcher{
+		controller: c,
+		scheme:     scheme,
+		watched:    map[schema.GroupVersionKind]bool{},
+	}
+}
+
+// Watch watches the type of every given resource that is not watched yet
+func (w *Watcher) Watch(list []Reconcilable) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, res := range list {
+		obj := res.Type()
+		gvk, err := apiutil.GVKForObject(obj, w.scheme)
+		if err != nil {
+			return err
+		}
+		if w.watched[gvk] {
+			continue
+		}
+		if err := w.controller.Watch(&source.Kind{Type: obj}, EnqueueRequestForOwner()); err != nil {
+			return err
+		}
+		w.watched[gvk] = true
+	}
+	return nil
+}
+
+// EnqueueRequestForOwner returns an event handler that sends a reconcile request
+// for the Nfs that owns the object. The owner is found by the owner labels, used
+// by the cluster scoped resources, or by the controller reference
+func EnqueueRequestForOwner() handler.EventHandler {
+	return &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(ownerRequests),
+	}
+}
+
+// EnqueueRequestForStorageClassOwner returns an event handler that sends a
+// reconcile request for the Nfs that owns the StorageClass of a
+// PersistentVolume, the StorageClass is read with the given client
+func EnqueueRequestForStorageClassOwner(c client.Reader) handler.EventHandler {
+	return &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			pv, ok := obj.Object.(*corev1.PersistentVolume)
+			if !ok || len(pv.Spec.StorageClassName) == 0 {
+				return nil
+			}
+			sc := &storagev1.StorageClass{}
+			if err := c.Get(context.TODO(), types.NamespacedName{Name: pv.Spec.StorageClassName}, sc); err != nil {
+				return nil
+			}
+			return ownerRequests(handler.MapObject{Meta: sc, Object: sc})
+		}),
+	}
+}
+
+func ownerRequests(obj handler.MapObject) []reconcile.Request {
+	labels := obj.Meta.GetLabels()
+	if name, ok := labels[LabelOwnerName]; ok {
+		return []reconcile.Request{
+			{NamespacedName: types.NamespacedName{Name: name, Namespace: labels[LabelOwnerNamespace]}},
+		}
+	}
+
+	ref := metav1.GetControllerOf(obj.Meta)
+	if ref == nil || ref.Kind != "Nfs" {
+		return nil
+	}
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil || gv.Group != ibmcloudv1alpha1.SchemeGroupVersion.Group {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: ref.Name, Namespace: obj.Meta.GetNamespace()}},
+	}
+}
@@ -0,0 +1,72 @@
+package resources
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestEnqueueRequestForStorageClassOwner(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewFakeClientWithScheme(scheme,
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "test-nfs", Labels: map[string]string{LabelOwnerName: "nfs", LabelOwnerNamespace: "test"}}},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "block"}},
+	)
+
+	tests := []struct {
+		name         string
+		storageClass string
+		want         []reconcile.Request
+	}{
+		{
+			name:         "StorageClass of a Nfs",
+			storageClass: "test-nfs",
+			want:         []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "test", Name: "nfs"}}},
+		},
+		{
+			name:         "other StorageClass",
+			storageClass: "block",
+		},
+		{
+			name:         "StorageClass not found",
+			storageClass: "deleted",
+		},
+		{
+			name: "without StorageClass",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pv := &corev1.PersistentVolume{
+				ObjectMeta: metav1.ObjectMeta{Name: "pvc-1"},
+				Spec:       corev1.PersistentVolumeSpec{StorageClassName: tt.storageClass},
+			}
+			q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+			defer q.ShutDown()
+			EnqueueRequestForStorageClassOwner(c).Create(event.CreateEvent{Meta: pv, Object: pv}, q)
+
+			var got []reconcile.Request
+			for q.Len() > 0 {
+				item, _ := q.Get()
+				got = append(got, item.(reconcile.Request))
+				q.Done(item)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("requests = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}